@@ -23,7 +23,12 @@ import (
 	"github.com/utrack/caisson-go/levels/level3/servers/l3http"
 	"github.com/utrack/caisson-go/log"
 	"github.com/utrack/caisson-go/pkg/caisenv"
+	"github.com/utrack/caisson-go/pkg/http/accesslog"
+	"github.com/utrack/caisson-go/pkg/http/debugmode"
 	"github.com/utrack/caisson-go/pkg/http/hhandler"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+	"github.com/utrack/caisson-go/pkg/http/recoverhttp"
+	"github.com/utrack/caisson-go/pkg/http/ws"
 	"github.com/utrack/caisson-go/pkg/plconfig"
 	"github.com/utrack/pontoon/sdesc"
 	"golang.org/x/sync/errgroup"
@@ -106,9 +111,14 @@ func (a *App) Run(ctx context.Context, services ...sdesc.Service) error {
 	// metrics, recovery, tracer etc.
 	// They should go in front of the app-provided middlewares.
 	hsrv.Apply(func(o *hhandler.Options) {
-		o.Middlewares = append([]func(http.Handler) http.Handler{
+		critical := []func(http.Handler) http.Handler{
 
 			chimw.RealIP,
+			debugmode.Middleware(debugmode.Options{
+				Header: cfg.DebugRequests.Header,
+				Tokens: cfg.DebugRequests.Tokens,
+				Secret: []byte(cfg.DebugRequests.Secret),
+			}),
 			otelchi.Middleware(caiconf.ServiceName,
 				otelchi.WithTraceResponseHeaders(otelchi.TraceHeaderConfig{
 					TraceIDHeader:      "X-Trace-Id",
@@ -118,13 +128,28 @@ func (a *App) Run(ctx context.Context, services ...sdesc.Service) error {
 			otelchimetric.NewRequestDurationMillis(otelChiCfg),
 			otelchimetric.NewRequestInFlight(otelChiCfg),
 			otelchimetric.NewResponseSizeBytes(otelChiCfg),
-			chimw.Recoverer,
-		}, o.Middlewares...)
+			debugmode.EchoTraceID,
+		}
+		if cfg.AccessLog.Enable {
+			critical = append(critical, accesslog.Middleware(accesslog.Options{
+				DropRatio:    1 - cfg.AccessLog.SampleRatio,
+				ExcludePaths: cfg.AccessLog.ExcludePaths,
+			}))
+		}
+		critical = append(critical, recoverhttp.Middleware(hsrv.Marshaler()))
+		o.Middlewares = append(critical, o.Middlewares...)
 	})
 
+	if err := errors.CheckCatalogue(); err != nil {
+		return errors.Wrap(err, "when checking the registered error types")
+	}
+
 	handlerDocMeta := []oapigen.HandlerDesc{}
 	for i, s := range services {
-		hdl, err := sdescbind.Bind(s, a.handlers.http)
+		hdl, err := sdescbind.Bind(s, a.handlers.http, hsrv.Extensions().ResponseStyle,
+			httpbinding.WithMaxBodySize(cfg.Server.MaxBodySize),
+			httpbinding.WithDisallowUnknownFields(cfg.Server.DisallowUnknownFields),
+		)
 		if err != nil {
 			return errors.Wrapf(err, "when binding HTTP handlers for service %d (%T)", i, s)
 		}
@@ -185,6 +210,9 @@ func (a *App) Run(ctx context.Context, services ...sdesc.Service) error {
 	}
 
 	closer.RegisterFuncC(a.hsrv.GracefulStop)
+	// closers run in reverse; Shutdown doesn't touch the hijacked WebSocket connections,
+	// so close them first with the Going Away frames - ws.Shutdown rejects the upgrades from then on
+	closer.RegisterFuncC(ws.Shutdown)
 
 	a.eg.Go(func() error {
 		err := a.hsrv.Run(ctx, finalHandler)
@@ -0,0 +1,541 @@
+/*
+Package caiclient builds typed HTTP clients for the sdesc services served by caiapp.
+
+Declare a struct of func fields named after the service's handler methods, mirroring their inputs and outputs:
+
+	type UsersClient struct {
+		GetUser    func(context.Context, users.GetUserInput) (users.User, error)
+		DeleteUser func(context.Context, users.DeleteUserInput) error
+	}
+
+	var c UsersClient
+	err := caiclient.Bind(&users.Service{}, "http://users:8080/api", &c)
+
+Bind collects the routes the service registers, and fills the fields with the functions calling them;
+the service's handlers themselves are never called, so a zero service value is enough.
+
+The inputs' `in`-tagged fields are encoded via httpin exactly as they are decoded by the server
+(path, query, headers etc.), the untagged ones are sent as the body; JSON by default, see WithCodec.
+
+Problem responses are decoded into errors.Coded errors, so errors.Code(err).Type()
+is the type of the error returned by the server's handler. The Coders registered
+in the catalogue (see errors.Register) are matched by errors.Is, and the registered CoderDetailers'
+details are extracted as usual; the problems' extensions are available via errors.KeyedData.
+
+Streaming, WebSocket and file upload handlers are not supported.
+*/
+package caiclient
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ggicci/httpin"
+	"github.com/ggicci/httpin/core"
+	"github.com/utrack/caisson-go/caiapp/internal/svcopt"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/pontoon/sdesc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var (
+	typeCtx   = reflect.TypeFor[context.Context]()
+	typeError = reflect.TypeFor[error]()
+)
+
+// Option configures the client.
+type Option func(*options)
+
+type options struct {
+	client *http.Client
+	style  *negmarshal.Style
+	codec  negmarshal.Codec
+}
+
+// WithHTTPClient sets the client making the requests; http.DefaultClient is used by default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithResponseStyle sets the style of the server's responses.
+//
+// By default, it is the service's style set via service.WithResponseStyle, or the enveloped one.
+// Set it if the server's app uses handler.WithResponseStyle.
+func WithResponseStyle(style negmarshal.Style) Option {
+	return func(o *options) {
+		o.style = &style
+	}
+}
+
+// WithCodec sets the format of the requests' bodies and of the accepted responses; JSON by default.
+// The server should have the codec registered (see negmarshal.Register), JSON responses are accepted as a fallback.
+//
+// The codec decodes the responses to the structs by their json tags, like negmarshal's
+// JSON, YAML, MsgPack and CBOR ones do.
+func WithCodec(c negmarshal.Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// Bind fills the func fields of the struct client points to with the calls of the service's handlers
+// served at baseURL, including the app's prefix.
+//
+// Every func field should be named after a handler's method and accept the context.Context
+// followed by the handler's input type, if any; it returns the handler's results.
+func Bind(s sdesc.Service, baseURL string, client any, opts ...Option) error {
+	rv := reflect.ValueOf(client)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("client should be a pointer to struct, got %T", client)
+	}
+
+	o := options{client: http.DefaultClient, codec: negmarshal.JSON()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	style := negmarshal.StyleEnveloped
+	sconfig := sdesc.HandlerConfig{}
+	for _, opt := range s.ServiceOptions() {
+		opt(&sconfig)
+	}
+	if sopts, _ := svcopt.Extract(sconfig); sopts.ResponseStyle != nil {
+		style = *sopts.ResponseStyle
+	}
+	if o.style != nil {
+		style = *o.style
+	}
+
+	r := &router{routes: map[string]route{}}
+	s.RegisterHTTP(r)
+	if r.err != nil {
+		return r.err
+	}
+
+	cv := rv.Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		f := cv.Type().Field(i)
+		if !f.IsExported() || f.Type.Kind() != reflect.Func {
+			continue
+		}
+		rt, ok := r.routes[f.Name]
+		if !ok {
+			return errors.Errorf("service %T has no handler named %v", s, f.Name)
+		}
+		c, err := newCall(rt, f.Type, strings.TrimSuffix(baseURL, "/"), o.client, style, o.codec)
+		if err != nil {
+			return errors.Wrapf(err, "when binding %v", f.Name)
+		}
+		cv.Field(i).Set(reflect.MakeFunc(f.Type, c.invoke))
+	}
+	return nil
+}
+
+// route is a handler registered by the service.
+type route struct {
+	method  string
+	pattern string
+	handler reflect.Type
+	meta    httpbinding.Meta
+}
+
+// router collects the service's routes by the handlers' names.
+type router struct {
+	routes map[string]route
+	err    error
+}
+
+func (r *router) MethodFunc(method, pattern string, hdl sdesc.RPCHandler) {
+	_, meta, err := httpbinding.BindHTTPHandlerMeta(hdl, negmarshal.Default())
+	if err != nil {
+		r.err = errors.Wrapd(err, "when binding HTTP handler", "method", method, "pattern", pattern)
+		return
+	}
+	r.routes[handlerName(hdl)] = route{
+		method:  method,
+		pattern: pattern,
+		handler: reflect.TypeOf(hdl),
+		meta:    meta,
+	}
+}
+
+// handlerName is the name of the handler's function or method, like GetUser for svc.GetUser.
+func handlerName(h any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	// method values are named like pkg.(*Service).GetUser-fm
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// chi's regexp params like {id:[0-9]+} are encoded as {id} by httpin
+var rePatternParam = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+// call is a bound client function.
+type call struct {
+	method string
+	url    string
+	client *http.Client
+	style  negmarshal.Style
+	codec  negmarshal.Codec
+	accept string
+
+	httpin *core.Core
+	// body encodes the input's body, if any
+	body func(in reflect.Value) any
+
+	outType reflect.Type
+	hasOut  bool
+	// envelope is the type of the enveloped responses, its Data points to the output's body
+	envelope reflect.Type
+}
+
+func newCall(rt route, ft reflect.Type, baseURL string, client *http.Client, style negmarshal.Style, codec negmarshal.Codec) (*call, error) {
+	switch {
+	case rt.meta.Streaming:
+		return nil, errors.New("streaming handlers are not supported")
+	case rt.meta.WebSocket:
+		return nil, errors.New("WebSocket handlers are not supported")
+	case rt.meta.WriterIntercepted:
+		return nil, errors.New("handlers writing to http.ResponseWriter are not supported")
+	}
+
+	if ft.NumIn() < 1 || ft.In(0) != typeCtx {
+		return nil, errors.New("client function should accept context.Context first")
+	}
+	in := rt.meta.InputType
+	switch {
+	case in == nil && ft.NumIn() != 1:
+		return nil, errors.New("handler has no input, client function should accept context.Context only")
+	case in != nil && (ft.NumIn() != 2 || ft.In(1) != in):
+		return nil, errors.Errorf("client function should accept context.Context and %v", in)
+	}
+	if ft.NumOut() != rt.handler.NumOut() {
+		return nil, errors.Errorf("client function should return %d values like the handler", rt.handler.NumOut())
+	}
+	for i := 0; i < ft.NumOut(); i++ {
+		if ft.Out(i) != rt.handler.Out(i) {
+			return nil, errors.Errorf("client function's result #%d should be %v", i, rt.handler.Out(i))
+		}
+	}
+
+	c := &call{
+		method: rt.method,
+		url:    baseURL + rePatternParam.ReplaceAllString(rt.pattern, "{$1}"),
+		client: client,
+		style:  style,
+		codec:  codec,
+		accept: codec.ContentType(),
+		hasOut: ft.NumOut() == 2,
+	}
+	if fallback := negmarshal.JSON().ContentType(); c.accept != fallback {
+		c.accept += ", " + fallback + ";q=0.5"
+	}
+
+	dataType := reflect.TypeFor[any]()
+	if c.hasOut {
+		c.outType = ft.Out(0)
+		dataType = reflect.TypeOf(bodyOf(reflect.New(c.outType).Elem()))
+	}
+	c.envelope = reflect.StructOf([]reflect.StructField{
+		{Name: "Data", Type: dataType, Tag: `json:"data"`},
+		{Name: "Error", Type: reflect.TypeFor[*problem](), Tag: `json:"error"`},
+		{Name: "Success", Type: reflect.TypeFor[bool](), Tag: `json:"success"`},
+	})
+	if in != nil {
+		if err := c.bindInput(in); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// bodyOf returns the pointer to decode the response's body to: the output itself,
+// or the body of the httpbinding.Response.
+func bodyOf(out reflect.Value) any {
+	if r, ok := out.Addr().Interface().(httpbinding.ResponseReader); ok {
+		return r.ResponseBody()
+	}
+	return out.Addr().Interface()
+}
+
+// bindInput prepares the input's encoding: the `in`-tagged fields are encoded by httpin,
+// the rest are sent in the body.
+func (c *call) bindInput(in reflect.Type) error {
+	t := in
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		c.body = func(v reflect.Value) any { return v.Interface() }
+		return nil
+	}
+
+	var bodyFields [][]int
+	var tagged bool
+	var walk func(t reflect.Type, index []int) error
+	walk = func(t reflect.Type, index []int) error {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(append([]int(nil), index...), i)
+			switch {
+			case f.Tag.Get("in") != "":
+				tagged = true
+			case f.Type == reflect.TypeFor[*httpbinding.File]() || f.Type == reflect.TypeFor[[]*httpbinding.File]():
+				return errors.Errorf("file field %v is not supported", f.Name)
+			case f.Anonymous && f.Type.Kind() == reflect.Struct:
+				if err := walk(f.Type, idx); err != nil {
+					return err
+				}
+			case f.IsExported():
+				bodyFields = append(bodyFields, idx)
+			}
+		}
+		return nil
+	}
+	if err := walk(t, nil); err != nil {
+		return err
+	}
+
+	if tagged {
+		engine, err := httpin.New(reflect.New(t).Interface())
+		if err != nil {
+			return errors.Wrap(err, "failed to create HTTPin encoder")
+		}
+		c.httpin = engine
+	}
+	if len(bodyFields) == 0 {
+		return nil
+	}
+
+	// the body is a struct of the untagged fields only, keeping their json tags
+	fields := make([]reflect.StructField, 0, len(bodyFields))
+	for _, idx := range bodyFields {
+		f := t.FieldByIndex(idx)
+		fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+	}
+	bodyType := reflect.StructOf(fields)
+	c.body = func(v reflect.Value) any {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		ret := reflect.New(bodyType).Elem()
+		for i, idx := range bodyFields {
+			ret.Field(i).Set(v.FieldByIndex(idx))
+		}
+		return ret.Interface()
+	}
+	return nil
+}
+
+func (c *call) invoke(args []reflect.Value) []reflect.Value {
+	ctx, _ := args[0].Interface().(context.Context)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var in reflect.Value
+	if len(args) > 1 {
+		in = args[1]
+	}
+
+	out, err := c.do(ctx, in)
+
+	errValue := reflect.Zero(typeError)
+	if err != nil {
+		errValue = reflect.ValueOf(&err).Elem()
+	}
+	if !c.hasOut {
+		return []reflect.Value{errValue}
+	}
+	if !out.IsValid() {
+		out = reflect.Zero(c.outType)
+	}
+	return []reflect.Value{out, errValue}
+}
+
+func (c *call) do(ctx context.Context, in reflect.Value) (reflect.Value, error) {
+	var req *http.Request
+	var err error
+	if c.httpin != nil {
+		// httpin encodes the addressable values only
+		ptr := in
+		if ptr.Kind() != reflect.Pointer {
+			ptr = reflect.New(in.Type())
+			ptr.Elem().Set(in)
+		}
+		req, err = c.httpin.NewRequestWithContext(ctx, c.method, c.url, ptr.Interface())
+	} else {
+		req, err = http.NewRequestWithContext(ctx, c.method, c.url, nil)
+	}
+	if err != nil {
+		return reflect.Value{}, errors.Wrap(err, "failed to build the request")
+	}
+
+	if c.body != nil {
+		if body := c.body(in); body != nil {
+			var b bytes.Buffer
+			if err := c.codec.Encode(&b, body); err != nil {
+				return reflect.Value{}, errors.Wrap(err, "failed to encode the request body")
+			}
+			buf := b.Bytes()
+			req.Body = io.NopCloser(bytes.NewReader(buf))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(buf)), nil
+			}
+			req.ContentLength = int64(len(buf))
+			req.Header.Set("Content-Type", c.codec.ContentType())
+		}
+	}
+	req.Header.Set("Accept", c.accept)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	rsp, err := c.client.Do(req)
+	if err != nil {
+		return reflect.Value{}, errors.Wrapf(err, "%v %v failed", c.method, req.URL.Path)
+	}
+	defer rsp.Body.Close()
+
+	out, err := c.decode(rsp)
+	if err != nil {
+		return reflect.Value{}, errors.Wrapf(err, "%v %v failed", c.method, req.URL.Path)
+	}
+	return out, nil
+}
+
+// problem is the problem document written by errmarshalhttp.ToRFC7807.
+type problem struct {
+	Type       string         `json:"type"`
+	Title      string         `json:"title"`
+	Status     int            `json:"status"`
+	Detail     string         `json:"detail"`
+	Extensions map[string]any `json:"extensions"`
+}
+
+func (c *call) decode(rsp *http.Response) (reflect.Value, error) {
+	buf, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return reflect.Value{}, errors.Wrap(err, "failed to read the response")
+	}
+	mt, _, _ := mime.ParseMediaType(rsp.Header.Get("Content-Type"))
+	codec, isProblem := c.codecFor(mt)
+	_, valueOnly := codec.(negmarshal.ValueOnlyCodec)
+
+	switch {
+	case isProblem:
+		var p problem
+		if err := codec.Decode(bytes.NewReader(buf), &p); err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "failed to decode the problem response with status %d", rsp.StatusCode)
+		}
+		return reflect.Value{}, problemError(&p, rsp)
+	case codec != nil && !valueOnly && c.style == negmarshal.StyleEnveloped:
+		env := reflect.New(c.envelope).Elem()
+		if err := codec.Decode(bytes.NewReader(buf), env.Addr().Interface()); err != nil {
+			return reflect.Value{}, errors.Wrapf(err, "failed to decode the response with status %d", rsp.StatusCode)
+		}
+		if !env.Field(2).Bool() {
+			p, _ := env.Field(1).Interface().(*problem)
+			if p == nil {
+				p = &problem{}
+			}
+			return reflect.Value{}, problemError(p, rsp)
+		}
+		if rsp.StatusCode >= 400 {
+			return reflect.Value{}, errors.Errorf("unexpected response status %d", rsp.StatusCode)
+		}
+		if !c.hasOut {
+			return reflect.Value{}, nil
+		}
+		ret := c.output(rsp)
+		if data := env.Field(0); !data.IsNil() {
+			reflect.ValueOf(bodyOf(ret)).Elem().Set(data.Elem())
+		}
+		return ret, nil
+	}
+	if rsp.StatusCode >= 400 {
+		return reflect.Value{}, errors.Errorf("unexpected response status %d: %s", rsp.StatusCode, buf)
+	}
+	if !c.hasOut {
+		return reflect.Value{}, nil
+	}
+
+	ret := c.output(rsp)
+	if codec == nil {
+		codec = c.codec
+	}
+	if len(bytes.TrimSpace(buf)) > 0 && !bytes.Equal(buf, []byte("null")) {
+		if err := codec.Decode(bytes.NewReader(buf), bodyOf(ret)); err != nil {
+			return reflect.Value{}, errors.Wrap(err, "failed to decode the response")
+		}
+	}
+	return ret, nil
+}
+
+// output returns the zero output, with the response's status and headers read if it's a httpbinding.Response.
+func (c *call) output(rsp *http.Response) reflect.Value {
+	ret := reflect.New(c.outType).Elem()
+	if r, ok := ret.Addr().Interface().(httpbinding.ResponseReader); ok {
+		r.ReadResponse(rsp.StatusCode, rsp.Header)
+	}
+	return ret
+}
+
+// codecFor returns the codec of the response's media type, and whether it's a problem document;
+// the client's codec is preferred, then the registered ones (see negmarshal.Register).
+func (c *call) codecFor(mt string) (negmarshal.Codec, bool) {
+	for _, codec := range append([]negmarshal.Codec{c.codec, negmarshal.JSON()}, negmarshal.Codecs()...) {
+		if _, valueOnly := codec.(negmarshal.ValueOnlyCodec); valueOnly && mt == codec.ProblemContentType() {
+			// the value-only codecs' problems are written by the other codecs
+			continue
+		}
+		switch mt {
+		case codec.ContentType():
+			return codec, false
+		case codec.ProblemContentType():
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// problemError converts the problem document to the error via errors.WireError: the problems
+// of the registered Coders are converted back to their errors, the rest to the new Coded ones.
+// The extensions are kept as the public data, and Retry-After header as the Coder's RetryAfter.
+func problemError(p *problem, rsp *http.Response) error {
+	status := cmp.Or(p.Status, rsp.StatusCode)
+	w := errors.WireError{Detail: p.Detail, Data: make(map[string]json.RawMessage, len(p.Extensions))}
+	for k, v := range p.Extensions {
+		if raw, err := json.Marshal(v); err == nil {
+			w.Data[k] = raw
+		}
+	}
+
+	if p.Type == "" {
+		if w.Detail == "" {
+			w.Detail = cmp.Or(p.Title, http.StatusText(status))
+		}
+		return errors.Wrapf(w.Err(), "server responded with status %d", status)
+	}
+	w.Type, w.HTTPCode, w.Message = p.Type, status, p.Title
+	if secs, err := strconv.Atoi(rsp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		w.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return w.Err()
+}
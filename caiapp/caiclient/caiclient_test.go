@@ -0,0 +1,188 @@
+package caiclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/caiapp/internal/sdescbind"
+	"github.com/utrack/caisson-go/caiapp/service"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/pontoon/sdesc"
+)
+
+type lockDetail struct {
+	Owner string `json:"owner"`
+}
+
+var (
+	errNotFound  = errors.NewCoder("ITEM_NOT_FOUND").WithHTTPCode(404).WithMessage("item not found")
+	errLocked    = errors.Register(errors.NewCoderDetailer[lockDetail]("TEST_CLIENT_ITEM_LOCKED").WithHTTPCode(423).WithMessage("item locked"))
+	errThrottled = errors.NewCoder("THROTTLED").WithHTTPCode(429).RetryAfter(2 * time.Second)
+)
+
+type getItemInput struct {
+	ID     string `in:"path=id"`
+	Fields string `in:"query=fields"`
+	Tenant string `in:"header=X-Tenant"`
+}
+
+type putItemInput struct {
+	ID   string `in:"path=id"`
+	Name string `json:"name"`
+}
+
+type item struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Fields string `json:"fields"`
+	Tenant string `json:"tenant"`
+}
+
+type itemService struct {
+	style negmarshal.Style
+}
+
+func (s itemService) ServiceOptions() []sdesc.ServiceOption {
+	return []sdesc.ServiceOption{service.WithResponseStyle(s.style)}
+}
+
+func (s itemService) RegisterHTTP(r sdesc.HTTPRouter) {
+	r.MethodFunc("GET", "/items/{id:[a-z0-9]+}", s.GetItem)
+	r.MethodFunc("PUT", "/items/{id}", s.PutItem)
+	r.MethodFunc("POST", "/ping", s.Ping)
+}
+
+func (itemService) GetItem(_ context.Context, in getItemInput) (item, error) {
+	switch in.ID {
+	case "missing":
+		return item{}, errNotFound.Wrap(errors.New("no such item"))
+	case "locked":
+		return item{}, errors.WithPublicData(errLocked.Wrap(errors.New("row lock"), lockDetail{Owner: "bob"}), "attempt", 2)
+	case "throttled":
+		return item{}, errThrottled.Wrap(errors.New("too many"))
+	}
+	return item{ID: in.ID, Fields: in.Fields, Tenant: in.Tenant}, nil
+}
+
+func (itemService) PutItem(_ context.Context, in putItemInput) (httpbinding.Response[item], error) {
+	return httpbinding.Response[item]{Body: item{ID: in.ID, Name: in.Name}, Status: http.StatusCreated}, nil
+}
+
+func (itemService) Ping(context.Context) error {
+	return nil
+}
+
+type itemClient struct {
+	GetItem func(context.Context, getItemInput) (item, error)
+	PutItem func(context.Context, putItemInput) (httpbinding.Response[item], error)
+	Ping    func(context.Context) error
+}
+
+func TestBind(t *testing.T) {
+	for _, style := range []negmarshal.Style{negmarshal.StyleEnveloped, negmarshal.StyleRaw} {
+		t.Run(style.String(), func(t *testing.T) {
+			so := require.New(t)
+			ctx := context.Background()
+
+			svc := itemService{style: style}
+			mux := chi.NewRouter()
+			_, err := sdescbind.Bind(svc, mux, negmarshal.StyleEnveloped)
+			so.NoError(err)
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			var c itemClient
+			so.NoError(Bind(svc, srv.URL, &c))
+
+			got, err := c.GetItem(ctx, getItemInput{ID: "a1", Fields: "name", Tenant: "acme"})
+			so.NoError(err)
+			so.Equal(item{ID: "a1", Fields: "name", Tenant: "acme"}, got)
+
+			rsp, err := c.PutItem(ctx, putItemInput{ID: "b2", Name: "bolt"})
+			so.NoError(err)
+			so.Equal(http.StatusCreated, rsp.Status)
+			so.Equal(item{ID: "b2", Name: "bolt"}, rsp.Body)
+
+			so.NoError(c.Ping(ctx))
+
+			_, err = c.GetItem(ctx, getItemInput{ID: "missing"})
+			so.Error(err)
+			code := errors.Code(err)
+			so.NotNil(code)
+			so.Equal("ITEM_NOT_FOUND", code.Type())
+			so.Equal(404, code.HTTPCode())
+			so.Equal("item not found", code.Message())
+			so.Equal("GET /items/missing failed: item not found", err.Error())
+
+			_, err = c.GetItem(ctx, getItemInput{ID: "locked"})
+			so.Equal("TEST_CLIENT_ITEM_LOCKED", errors.Code(err).Type())
+			so.Equal(&lockDetail{Owner: "bob"}, errLocked.ExtractDetail(err))
+			attempt, ok := errors.KeyedData[string, string](err, "attempt")
+			so.True(ok)
+			so.Equal("2", attempt)
+
+			_, err = c.GetItem(ctx, getItemInput{ID: "throttled"})
+			delay, ok := errors.RetryAfter(err)
+			so.True(ok)
+			so.Equal(2*time.Second, delay)
+		})
+	}
+}
+
+func TestBind_codec(t *testing.T) {
+	negmarshal.Register(negmarshal.MsgPack())
+	t.Cleanup(func() { negmarshal.Unregister(negmarshal.MsgPack().ContentType()) })
+
+	for _, style := range []negmarshal.Style{negmarshal.StyleEnveloped, negmarshal.StyleRaw} {
+		t.Run(style.String(), func(t *testing.T) {
+			so := require.New(t)
+			ctx := context.Background()
+
+			svc := itemService{style: style}
+			mux := chi.NewRouter()
+			_, err := sdescbind.Bind(svc, mux, negmarshal.StyleEnveloped)
+			so.NoError(err)
+			var contentTypes []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+				mux.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			var c itemClient
+			so.NoError(Bind(svc, srv.URL, &c, WithCodec(negmarshal.MsgPack())))
+
+			rsp, err := c.PutItem(ctx, putItemInput{ID: "b2", Name: "bolt"})
+			so.NoError(err)
+			so.Equal(http.StatusCreated, rsp.Status)
+			so.Equal("application/msgpack", rsp.Header.Get("Content-Type"))
+			so.Equal(item{ID: "b2", Name: "bolt"}, rsp.Body)
+			so.Equal([]string{"application/msgpack"}, contentTypes)
+
+			_, err = c.GetItem(ctx, getItemInput{ID: "locked"})
+			so.Equal("TEST_CLIENT_ITEM_LOCKED", errors.Code(err).Type())
+			so.Equal(&lockDetail{Owner: "bob"}, errLocked.ExtractDetail(err))
+		})
+	}
+}
+
+func TestBind_mismatch(t *testing.T) {
+	so := require.New(t)
+
+	var c struct {
+		GetItem func(context.Context, putItemInput) (item, error)
+	}
+	so.Error(Bind(itemService{}, "http://localhost", &c))
+
+	var unknown struct {
+		DeleteItem func(context.Context) error
+	}
+	so.Error(Bind(itemService{}, "http://localhost", &unknown))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/utrack/caisson-go/caiapp/internal/hchi"
 	"github.com/utrack/caisson-go/pkg/http/hhandler"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
 )
 
 type OptionHTTP = hhandler.OptionHTTP
@@ -22,3 +23,16 @@ func WithPrefix(prefix string) OptionHTTP {
 		o.Extensions = exts
 	}
 }
+
+// WithResponseStyle sets the default shape of the app's responses.
+//
+// Use negmarshal.StyleRaw to drop the {data, error, success} envelope and respond
+// with bare values and application/problem+json errors.
+// Services can override it via [github.com/utrack/caisson-go/caiapp/service.WithResponseStyle].
+func WithResponseStyle(style negmarshal.Style) OptionHTTP {
+	return func(o *hhandler.Options) {
+		exts := o.Extensions.(hchi.OptionExtensions)
+		exts.ResponseStyle = style
+		o.Extensions = exts
+	}
+}
@@ -10,6 +10,8 @@ import (
 type Config struct {
 	Server           Server
 	GracefulShutdown Grace
+	DebugRequests    DebugRequests
+	AccessLog        AccessLog
 }
 
 type Server struct {
@@ -18,6 +20,12 @@ type Server struct {
 
 	AddrDebug string `default:"0.0.0.0"`
 	PortDebug int    `default:"8082"`
+
+	// MaxBodySize limits the size of the requests' bodies, in bytes; 0 disables the limit.
+	// Services can override it via service.WithMaxBodySize.
+	MaxBodySize int64 `default:"10485760"`
+	// DisallowUnknownFields rejects the request bodies with the fields unknown to the handlers' inputs.
+	DisallowUnknownFields bool
 }
 
 type Grace struct {
@@ -28,6 +36,26 @@ type Grace struct {
 	Timeout time.Duration `default:"30s"`
 }
 
+// DebugRequests configures the per-request debug mode.
+// See [github.com/utrack/caisson-go/pkg/http/debugmode] for details.
+type DebugRequests struct {
+	Header string `default:"X-Debug-Token"`
+	// Tokens are the allowlisted static debug tokens.
+	Tokens []string
+	// Secret is the key for the signed debug tokens.
+	Secret string
+}
+
+// AccessLog configures the request logging.
+// See [github.com/utrack/caisson-go/pkg/http/accesslog] for details.
+type AccessLog struct {
+	Enable bool `default:"true"`
+	// SampleRatio is the ratio of the successful requests being logged, from 0 to 1.
+	SampleRatio float64 `default:"1"`
+	// ExcludePaths are the request paths which are not logged; a trailing '*' matches a prefix.
+	ExcludePaths []string
+}
+
 func read() (*Config, error) {
 	var c Config
 	err := envconfig.ProcessWithOptions("", &c, envconfig.Options{SplitWords: true})
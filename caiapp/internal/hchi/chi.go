@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/utrack/caisson-go/pkg/http/hhandler"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
 )
 
 var _ hhandler.Handler = &ChiHandler{}
@@ -18,6 +19,10 @@ type ChiHandler struct {
 
 type OptionExtensions struct {
 	Prefix string
+
+	// ResponseStyle is the default shape of the responses,
+	// including the platform's own ones like 404 and 405.
+	ResponseStyle negmarshal.Style
 }
 
 type route struct {
@@ -49,6 +54,11 @@ func (c *ChiHandler) Extensions() OptionExtensions {
 	return c.options.Extensions.(OptionExtensions)
 }
 
+// Marshaler returns the marshaler for the platform's own responses.
+func (c *ChiHandler) Marshaler() negmarshal.NegotiatedMarshalFunc {
+	return negmarshal.ForStyle(c.Extensions().ResponseStyle)
+}
+
 func (c *ChiHandler) MethodFunc(method string, pattern string, hdl http.HandlerFunc) {
 	c.routes = append(c.routes, route{method: method, pattern: pattern, handler: hdl})
 }
@@ -63,6 +73,11 @@ func (c *ChiHandler) Build() (*http.Server, error) {
 
 	router.Use(c.options.Middlewares...)
 
+	extensions := c.Extensions()
+	marshaler := c.Marshaler()
+	router.NotFound(notFoundHandler(marshaler))
+	router.MethodNotAllowed(methodNotAllowedHandler(router, marshaler))
+
 	for _, r := range c.routes {
 		switch r.method {
 		case "":
@@ -73,14 +88,11 @@ func (c *ChiHandler) Build() (*http.Server, error) {
 	}
 
 	var finalHandler http.Handler = router
-	if c.options.Extensions != nil {
-		extensions := c.options.Extensions.(OptionExtensions)
-
-		if extensions.Prefix != "" {
-			outerRouter := chi.NewRouter()
-			outerRouter.Mount(extensions.Prefix, router)
-			finalHandler = outerRouter
-		}
+	if extensions.Prefix != "" {
+		outerRouter := chi.NewRouter()
+		outerRouter.NotFound(notFoundHandler(marshaler))
+		outerRouter.Mount(extensions.Prefix, router)
+		finalHandler = outerRouter
 	}
 	srv.Handler = finalHandler
 	return srv, nil
@@ -0,0 +1,85 @@
+package hchi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/hhandler"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/http/recoverhttp"
+	"github.com/utrack/caisson-go/pkg/http/ws"
+	"github.com/utrack/caisson-go/pkg/validate"
+)
+
+func TestChiHandler_routingErrors(t *testing.T) {
+	so := require.New(t)
+
+	h := New()
+	h.Apply(func(o *hhandler.Options) {
+		exts := o.Extensions.(OptionExtensions)
+		exts.Prefix = "/api"
+		o.Extensions = exts
+	})
+	h.MethodFunc("GET", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	h.MethodFunc("DELETE", "/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	srv, err := h.Build()
+	so.NoError(err)
+
+	problemType := func(rsp *httptest.ResponseRecorder) string {
+		var body struct {
+			Error struct {
+				Type string `json:"type"`
+			} `json:"error"`
+		}
+		so.NoError(json.Unmarshal(rsp.Body.Bytes(), &body))
+		return body.Error.Type
+	}
+
+	rsp := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rsp, httptest.NewRequest("GET", "/api/nope", nil))
+	so.Equal(http.StatusNotFound, rsp.Code)
+	so.Equal("NOT_FOUND", problemType(rsp))
+
+	rsp = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rsp, httptest.NewRequest("GET", "/outside", nil))
+	so.Equal(http.StatusNotFound, rsp.Code)
+	so.Equal("NOT_FOUND", problemType(rsp))
+
+	rsp = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rsp, httptest.NewRequest("POST", "/api/users/1", nil))
+	so.Equal(http.StatusMethodNotAllowed, rsp.Code)
+	so.Equal("GET, DELETE", rsp.Header().Get("Allow"))
+	so.Equal("METHOD_NOT_ALLOWED", problemType(rsp))
+
+	req := httptest.NewRequest("GET", "/api/nope", nil)
+	req.Header.Set("Accept", "text/csv")
+	rsp = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rsp, req)
+	so.Equal(http.StatusNotAcceptable, rsp.Code)
+	so.Equal("NOT_ACCEPTABLE", problemType(rsp))
+}
+
+func TestPlatformErrorsRegistered(t *testing.T) {
+	so := require.New(t)
+
+	types := map[string]bool{}
+	for _, e := range errors.Catalogue() {
+		types[e.Type] = true
+	}
+	for _, c := range []errors.Coder{
+		ErrNotFound, ErrMethodNotAllowed,
+		httpbinding.ErrMalformedRequest, httpbinding.ErrRequestTooLarge,
+		negmarshal.ErrNotAcceptable, negmarshal.ErrUnsupportedMediaType,
+		validate.ErrInvalid, recoverhttp.ErrPanic, ws.ErrShuttingDown,
+	} {
+		spec, ok := errors.SpecOf(c)
+		so.True(ok)
+		so.True(types[spec.Type()], spec.Type())
+	}
+	so.NoError(errors.CheckCatalogue())
+}
@@ -0,0 +1,69 @@
+package hchi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+)
+
+var (
+	ErrNotFound         = errors.Register(errors.NewCoder("NOT_FOUND").WithHTTPCode(http.StatusNotFound).WithMessage("no route matches the request path"))
+	ErrMethodNotAllowed = errors.Register(errors.NewCoder("METHOD_NOT_ALLOWED").WithHTTPCode(http.StatusMethodNotAllowed).WithMessage("request method is not allowed for this route"))
+)
+
+// methods are the methods probed for the Allow header.
+var methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+func notFoundHandler(marshaler negmarshal.NegotiatedMarshalFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := errors.Wrapd(ErrNotFound.Wrap(errors.New("route not found")), "when routing the request", "path", r.URL.Path)
+		writeProblem(w, r, marshaler, err)
+	}
+}
+
+func methodNotAllowedHandler(router chi.Routes, marshaler negmarshal.NegotiatedMarshalFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed := allowedMethods(router, r)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+		err := errors.Wrapd(ErrMethodNotAllowed.Wrap(errors.New("method not allowed")), "when routing the request",
+			"method", r.Method, "allowed", strings.Join(allowed, ", "))
+		writeProblem(w, r, marshaler, err)
+	}
+}
+
+// allowedMethods lists the methods the router would accept for the request's path.
+func allowedMethods(router chi.Routes, r *http.Request) []string {
+	// replicate chi's routing path resolution, since the path may be relative to the mount point
+	path := r.URL.Path
+	if r.URL.RawPath != "" {
+		path = r.URL.RawPath
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		path = rctx.RoutePath
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	var ret []string
+	for _, m := range methods {
+		if router.Match(chi.NewRouteContext(), m, path) {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, marshaler negmarshal.NegotiatedMarshalFunc, err error) {
+	if merr := marshaler(r, w, nil, err); merr != nil && !errors.Is(merr, negmarshal.ErrNotAcceptable) {
+		log.Error(r.Context(), "failed to marshal the routing error", merr)
+	}
+}
@@ -1,7 +1,7 @@
 /*
 Package hdebug provides a separate server
 which serves the livez/readyz/healthz endpoints,
-as well as pprof handlers and Prometheus metrics.
+as well as pprof handlers, Prometheus metrics and the error catalogue.
 */
 package hdebug
 
@@ -19,6 +19,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/utrack/caisson-go/caiapp/handler"
 	"github.com/utrack/caisson-go/caiapp/internal/hchi"
+	"github.com/utrack/caisson-go/pkg/errcatalog"
 	"github.com/utrack/caisson-go/pkg/http/hhandler"
 )
 
@@ -57,6 +58,8 @@ func New() *Mux {
 
 	mux.HandleFunc("/debug/fgprof", fgprof.Handler().ServeHTTP)
 	mux.HandleFunc("/debug/vars", expvar.Handler().ServeHTTP)
+	mux.HandleFunc("/debug/errors", errcatalog.HandlerHTML().ServeHTTP)
+	mux.HandleFunc("/debug/errors.json", errcatalog.Handler().ServeHTTP)
 	mux.HandleFunc("/debug/bin", func(w http.ResponseWriter, r *http.Request) {
 		pp, err := os.Executable()
 		if err != nil {
@@ -162,6 +165,7 @@ var tplDebugHome = template.Must(template.New("foo").Parse(
 <li><a href="/debug/pprof">/debug/pprof</a> - Go pprof</li>
 <li><a href="/debug/fgprof">/debug/fgprof</a> - github.com/felixge/fgprof prof dump</li>
 <li><a href="/debug/vars">/debug/vars</a> - Go expvar</li>
+<li><a href="/debug/errors">/debug/errors</a> - catalogue of the registered error types (<a href="/debug/errors.json">JSON</a>)</li>
 </ul>
 </div>
     </body>
@@ -0,0 +1,100 @@
+package oapigen
+
+import (
+	"cmp"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/utrack/caisson-go/caiapp/internal/hchi"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/pontoon/v2/openapi/httpinmeditate"
+	"gopkg.in/yaml.v3"
+)
+
+// describeErrors documents the handlers' declared Coder errors as the responses
+// of their HTTP codes. Every response's problem schema lists the possible types,
+// and the CoderDetailers' details under their extensions' keys.
+func describeErrors(doc *v3.Document, handlers []HandlerDesc, ropts hchi.OptionExtensions) error {
+	details := httpinmeditate.NewGenerator()
+	detailRefs := map[reflect.Type]*base.SchemaProxy{}
+
+	for _, d := range handlers {
+		if len(d.Errors) == 0 {
+			continue
+		}
+		op := operation(doc, d.Method, path.Join(ropts.Prefix, d.Path))
+		if op == nil || op.Responses == nil {
+			continue
+		}
+
+		byCode := map[int][]errors.CoderSpec{}
+		for _, spec := range d.Errors {
+			byCode[spec.HTTPCode()] = append(byCode[spec.HTTPCode()], spec)
+		}
+		codes := make([]int, 0, len(byCode))
+		for code := range byCode {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+
+		for _, code := range codes {
+			specs := byCode[code]
+			slices.SortFunc(specs, func(a, b errors.CoderSpec) int { return cmp.Compare(a.Type(), b.Type()) })
+
+			types := []*yaml.Node{}
+			descs := []string{}
+			extProps := orderedmap.New[string, *base.SchemaProxy]()
+			for _, spec := range specs {
+				types = append(types, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: spec.Type()})
+				descs = append(descs, spec.Type()+": "+spec.Message())
+				if spec.DetailType == nil {
+					continue
+				}
+				ref, ok := detailRefs[spec.DetailType]
+				if !ok {
+					var err error
+					ref, err = details.JSONSchemaRef(spec.DetailType)
+					if err != nil {
+						return errors.Wrapf(err, "when generating the schema of %v's details", spec.Type())
+					}
+					if !ref.IsReference() && ref.Schema().SchemaTypeRef != "" {
+						ref = base.CreateSchemaProxyRef(ref.Schema().SchemaTypeRef)
+					}
+					detailRefs[spec.DetailType] = ref
+				}
+				// errorbag lists the details under their type's name
+				extProps.Set(spec.DetailType.String(), ref)
+			}
+
+			props := orderedmap.New[string, *base.SchemaProxy]()
+			props.Set("type", base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}, Enum: types}))
+			if extProps.Len() > 0 {
+				props.Set("extensions", base.CreateSchemaProxy(&base.Schema{Type: []string{"object"}, Properties: extProps}))
+			}
+			problem := base.CreateSchemaProxy(&base.Schema{AllOf: []*base.SchemaProxy{
+				base.CreateSchemaProxyRef(problemSchemaRef),
+				base.CreateSchemaProxy(&base.Schema{Type: []string{"object"}, Properties: props}),
+			}})
+
+			op.Responses.Codes.Set(strconv.Itoa(code), &v3.Response{
+				Description: strings.Join(descs, "\n\n"),
+				Content:     problemContent(d.ResponseStyle, problem),
+			})
+		}
+	}
+
+	if comps := details.Components(); comps != nil && comps.Schemas != nil {
+		for k, v := range comps.Schemas.FromOldest() {
+			if _, ok := doc.Components.Schemas.Get(k); !ok {
+				doc.Components.Schemas.Set(k, v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package oapigen
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/utrack/caisson-go/pkg/validate"
+	"gopkg.in/yaml.v3"
+)
+
+// pontoon's extensions linking the generated schemas to the Go types
+const (
+	extGoPackage   = "x-pontoon-go-package"
+	extGoType      = "x-pontoon-go-type"
+	extFieldGoName = "x-pontoon-field-go-name"
+)
+
+// describeConstraints documents the `validate` constraints (see package validate)
+// of the handlers' input and output types in their schemas and parameters.
+func describeConstraints(doc *v3.Document, handlers []HandlerDesc) {
+	types := map[string]reflect.Type{}
+	for _, d := range handlers {
+		collectTypes(d.Input, types)
+		collectTypes(d.Output, types)
+	}
+
+	if doc.Components != nil && doc.Components.Schemas != nil {
+		for pair := doc.Components.Schemas.First(); pair != nil; pair = pair.Next() {
+			s := pair.Value().Schema()
+			if s == nil {
+				continue
+			}
+			t, ok := types[goTypeKey(s.Extensions)]
+			if !ok {
+				continue
+			}
+			constrainProperties(s, t)
+		}
+	}
+
+	if doc.Paths == nil || doc.Paths.PathItems == nil {
+		return
+	}
+	for path := doc.Paths.PathItems.First(); path != nil; path = path.Next() {
+		for op := path.Value().GetOperations().First(); op != nil; op = op.Next() {
+			for _, p := range op.Value().Parameters {
+				t, ok := types[goTypeKey(p.Extensions)]
+				if !ok {
+					continue
+				}
+				f, ok := t.FieldByName(extension(p.Extensions, extFieldGoName))
+				if !ok || p.Schema == nil {
+					continue
+				}
+				if applyRules(p.Schema.Schema(), validate.Rules(f), f.Type) {
+					required := true
+					p.Required = &required
+				}
+			}
+		}
+	}
+}
+
+// constrainProperties applies the struct fields' rules to the struct's schema properties.
+func constrainProperties(s *base.Schema, t reflect.Type) {
+	if s.Properties == nil {
+		return
+	}
+	for prop := s.Properties.First(); prop != nil; prop = prop.Next() {
+		ps := prop.Value().Schema()
+		if ps == nil {
+			continue
+		}
+		f, ok := t.FieldByName(extension(ps.Extensions, extFieldGoName))
+		if !ok {
+			continue
+		}
+		if applyRules(ps, validate.Rules(f), f.Type) && !slices.Contains(s.Required, prop.Key()) {
+			s.Required = append(s.Required, prop.Key())
+		}
+	}
+}
+
+// applyRules documents the rules in the schema, returning whether the value is required.
+func applyRules(s *base.Schema, rules []validate.Rule, t reflect.Type) (required bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for _, r := range rules {
+		switch r.Name {
+		case "required":
+			required = true
+		case "min", "max":
+			applyBound(s, r.Name == "min", r.Param, t)
+		case "pattern":
+			s.Pattern = r.Param
+		case "enum":
+			tag := "!!str"
+			switch t.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				tag = "!!int"
+			case reflect.Float32, reflect.Float64:
+				tag = "!!float"
+			}
+			s.Enum = nil
+			for _, v := range strings.Split(r.Param, "|") {
+				s.Enum = append(s.Enum, &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: v})
+			}
+		}
+	}
+	return required
+}
+
+func applyBound(s *base.Schema, isMin bool, param string, t reflect.Type) {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		// validate.Compile has already rejected it while binding
+		return
+	}
+	n := int64(bound)
+
+	switch t.Kind() {
+	case reflect.String:
+		if isMin {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+	case reflect.Slice, reflect.Array:
+		if isMin {
+			s.MinItems = &n
+		} else {
+			s.MaxItems = &n
+		}
+	case reflect.Map:
+		if isMin {
+			s.MinProperties = &n
+		} else {
+			s.MaxProperties = &n
+		}
+	default:
+		if isMin {
+			s.Minimum = &bound
+		} else {
+			s.Maximum = &bound
+		}
+	}
+}
+
+// collectTypes indexes the named struct types reachable from t by their goTypeKey.
+func collectTypes(t reflect.Type, into map[string]reflect.Type) {
+	if t == nil {
+		return
+	}
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+			continue
+		}
+		break
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if t.Name() != "" {
+		key := t.PkgPath() + "." + t.Name()
+		if _, ok := into[key]; ok {
+			return
+		}
+		into[key] = t
+	}
+	for i := 0; i < t.NumField(); i++ {
+		collectTypes(t.Field(i).Type, into)
+	}
+}
+
+func goTypeKey(exts *orderedmap.Map[string, *yaml.Node]) string {
+	return extension(exts, extGoPackage) + "." + extension(exts, extGoType)
+}
+
+func extension(exts *orderedmap.Map[string, *yaml.Node], key string) string {
+	if exts == nil {
+		return ""
+	}
+	if n, ok := exts.Get(key); ok && n != nil {
+		return n.Value
+	}
+	return ""
+}
@@ -6,6 +6,8 @@ import (
 
 	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
 	"github.com/utrack/caisson-go/caiapp/internal/hchi"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
 	"github.com/utrack/pontoon/v2/httpinoapi"
 )
 
@@ -16,6 +18,16 @@ type HandlerDesc struct {
 	Func   any
 	Input  reflect.Type
 	Output reflect.Type
+	// StatusCodes are the successful responses' status codes; empty means 200 OK.
+	StatusCodes []int
+	// Streaming is set for the streamed outputs; Output is the stream's item type then.
+	Streaming bool
+	// WebSocket is set for the WebSocket endpoints; Output is the type of the sent messages then.
+	WebSocket bool
+	// Errors are the Coders the handler may return.
+	Errors []errors.CoderSpec
+
+	ResponseStyle negmarshal.Style
 }
 
 func GenerateOAPI(handlers []HandlerDesc, ropts hchi.OptionExtensions) (*v3.Document, error) {
@@ -32,5 +44,58 @@ func GenerateOAPI(handlers []HandlerDesc, ropts hchi.OptionExtensions) (*v3.Docu
 		gen.Operation(d.Method, path.Join(ropts.Prefix, d.Path), d.Func, opts...)
 	}
 
-	return gen.Build()
+	doc, err := gen.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	// httpinoapi knows nothing about the way caisson marshals the responses;
+	// reshape the generated operations accordingly
+	for _, d := range handlers {
+		op := operation(doc, d.Method, path.Join(ropts.Prefix, d.Path))
+		if op == nil {
+			continue
+		}
+		if err := describeResponses(doc, op, d); err != nil {
+			return nil, errors.Wrapf(err, "when describing responses for %v %v", d.Method, d.Path)
+		}
+	}
+
+	describeConstraints(doc, handlers)
+	describeUploads(doc, handlers, ropts)
+	if err := describeErrors(doc, handlers, ropts); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// operation returns the document's operation for the method and path, if any.
+func operation(doc *v3.Document, method string, p string) *v3.Operation {
+	if doc.Paths == nil {
+		return nil
+	}
+	item, ok := doc.Paths.PathItems.Get(p)
+	if !ok {
+		return nil
+	}
+	switch method {
+	case "GET":
+		return item.Get
+	case "POST":
+		return item.Post
+	case "PUT":
+		return item.Put
+	case "DELETE":
+		return item.Delete
+	case "PATCH":
+		return item.Patch
+	case "HEAD":
+		return item.Head
+	case "OPTIONS":
+		return item.Options
+	case "TRACE":
+		return item.Trace
+	}
+	return nil
 }
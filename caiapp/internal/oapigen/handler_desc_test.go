@@ -0,0 +1,223 @@
+package oapigen
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/caiapp/internal/hchi"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type testInput struct {
+	ID string `in:"path=id"`
+}
+
+type testOutput struct {
+	Name string `json:"name"`
+}
+
+func testHandler(context.Context, testInput) (testOutput, error) {
+	return testOutput{}, nil
+}
+
+func TestGenerateOAPI_responseStyles(t *testing.T) {
+	so := require.New(t)
+
+	doc, err := GenerateOAPI([]HandlerDesc{
+		{
+			Method: "GET", Path: "/enveloped/{id}", Func: testHandler,
+			Input: reflect.TypeFor[testInput](), Output: reflect.TypeFor[testOutput](),
+			ResponseStyle: negmarshal.StyleEnveloped,
+		},
+		{
+			Method: "GET", Path: "/raw/{id}", Func: testHandler,
+			Input: reflect.TypeFor[testInput](), Output: reflect.TypeFor[testOutput](),
+			ResponseStyle: negmarshal.StyleRaw,
+		},
+	}, hchi.OptionExtensions{Prefix: "/api"})
+	so.NoError(err)
+
+	_, err = doc.Render()
+	so.NoError(err)
+
+	enveloped := operation(doc, "GET", "/api/enveloped/{id}")
+	so.NotNil(enveloped)
+	ok, _ := enveloped.Responses.Codes.Get("200")
+	okSchema := ok.Content.GetOrZero("application/json").Schema.Schema()
+	so.True(okSchema.Properties.GetOrZero("data").IsReference())
+	so.NotNil(enveloped.Responses.Default.Content.GetOrZero("application/json"))
+
+	raw := operation(doc, "GET", "/api/raw/{id}")
+	so.NotNil(raw)
+	ok, _ = raw.Responses.Codes.Get("200")
+	so.True(ok.Content.GetOrZero("application/json").Schema.IsReference())
+	problem := raw.Responses.Default.Content.GetOrZero("application/problem+json")
+	so.NotNil(problem)
+	so.Equal(problemSchemaRef, problem.Schema.GetReference())
+}
+
+type constrainedBody struct {
+	Name  string `json:"name" validate:"required,max=5,pattern=^[a-z]+$"`
+	Count int    `json:"count" validate:"min=1,enum=1|2"`
+}
+
+type constrainedInput struct {
+	Tenant string          `in:"query=tenant" validate:"required,min=2"`
+	Body   constrainedBody `in:"body=json"`
+}
+
+func constrainedHandler(context.Context, constrainedInput) (constrainedBody, error) {
+	return constrainedBody{}, nil
+}
+
+func TestGenerateOAPI_constraints(t *testing.T) {
+	so := require.New(t)
+
+	doc, err := GenerateOAPI([]HandlerDesc{{
+		Method: "POST", Path: "/items", Func: constrainedHandler,
+		Input: reflect.TypeFor[constrainedInput](), Output: reflect.TypeFor[constrainedBody](),
+	}}, hchi.OptionExtensions{})
+	so.NoError(err)
+
+	param := operation(doc, "POST", "/items").Parameters[0]
+	so.True(*param.Required)
+	so.EqualValues(2, *param.Schema.Schema().MinLength)
+
+	body := doc.Components.Schemas.GetOrZero("github.com_utrack_caisson-go_caiapp_internal_oapigen.constrainedBody").Schema()
+	so.Equal([]string{"name"}, body.Required)
+
+	name := body.Properties.GetOrZero("name").Schema()
+	so.EqualValues(5, *name.MaxLength)
+	so.Equal("^[a-z]+$", name.Pattern)
+
+	count := body.Properties.GetOrZero("count").Schema()
+	so.EqualValues(1, *count.Minimum)
+	so.Len(count.Enum, 2)
+}
+
+func TestGenerateOAPI_statusCodes(t *testing.T) {
+	so := require.New(t)
+
+	doc, err := GenerateOAPI([]HandlerDesc{{
+		Method: "POST", Path: "/items/{id}", Func: testHandler,
+		Input: reflect.TypeFor[testInput](), Output: reflect.TypeFor[testOutput](),
+		StatusCodes: []int{201, 204},
+	}}, hchi.OptionExtensions{})
+	so.NoError(err)
+
+	codes := operation(doc, "POST", "/items/{id}").Responses.Codes
+	_, found := codes.Get("200")
+	so.False(found)
+	so.NotNil(codes.GetOrZero("201").Content.GetOrZero("application/json"))
+	so.Nil(codes.GetOrZero("204").Content)
+}
+
+func TestEncodes(t *testing.T) {
+	so := require.New(t)
+
+	pb := negmarshal.Protobuf()
+	so.True(encodes(pb, reflect.TypeFor[*wrapperspb.StringValue]()))
+	so.False(encodes(pb, reflect.TypeFor[wrapperspb.StringValue]()))
+	so.False(encodes(pb, reflect.TypeFor[testOutput]()))
+	so.False(encodes(pb, nil))
+	so.True(encodes(negmarshal.JSON(), reflect.TypeFor[testOutput]()))
+}
+
+func TestGenerateOAPI_streaming(t *testing.T) {
+	so := require.New(t)
+
+	doc, err := GenerateOAPI([]HandlerDesc{{
+		Method: "GET", Path: "/items/{id}/events", Func: testHandler,
+		Input: reflect.TypeFor[testInput](), Output: reflect.TypeFor[testOutput](),
+		Streaming: true,
+	}}, hchi.OptionExtensions{})
+	so.NoError(err)
+
+	ok, _ := operation(doc, "GET", "/items/{id}/events").Responses.Codes.Get("200")
+	so.Equal([]string{"application/x-ndjson", "text/event-stream"}, []string{ok.Content.Oldest().Key, ok.Content.Newest().Key})
+	so.True(ok.Content.GetOrZero("text/event-stream").Schema.IsReference())
+}
+
+type uploadInput struct {
+	ID          string              `in:"path=id"`
+	Title       string              `json:"title"`
+	Avatar      *httpbinding.File   `json:"avatar" validate:"required"`
+	Attachments []*httpbinding.File `json:"attachments"`
+}
+
+func uploadHandler(context.Context, uploadInput) error {
+	return nil
+}
+
+func TestGenerateOAPI_uploads(t *testing.T) {
+	so := require.New(t)
+
+	doc, err := GenerateOAPI([]HandlerDesc{{
+		Method: "POST", Path: "/items/{id}/files", Func: uploadHandler,
+		Input: reflect.TypeFor[uploadInput](),
+	}}, hchi.OptionExtensions{})
+	so.NoError(err)
+
+	body := operation(doc, "POST", "/items/{id}/files").RequestBody
+	so.NotNil(body)
+	form := body.Content.GetOrZero("multipart/form-data").Schema.Schema()
+	so.Equal([]string{"title", "avatar", "attachments"}, slices.Collect(form.Properties.KeysFromOldest()))
+	so.Equal([]string{"avatar"}, form.Required)
+	so.Equal("binary", form.Properties.GetOrZero("avatar").Schema().Format)
+	so.Equal("binary", form.Properties.GetOrZero("attachments").Schema().Items.A.Schema().Format)
+}
+
+type conflictDetail struct {
+	Holder string `json:"holder"`
+}
+
+func TestGenerateOAPI_errors(t *testing.T) {
+	so := require.New(t)
+
+	notFound := errors.NewCoder("ITEM_NOT_FOUND").WithHTTPCode(404).WithMessage("item not found")
+	locked := errors.NewCoderDetailer[conflictDetail]("ITEM_LOCKED").WithHTTPCode(409).WithMessage("item is locked")
+	gone := errors.NewCoder("ITEM_GONE").WithHTTPCode(404).WithMessage("item was deleted")
+
+	var specs []errors.CoderSpec
+	for _, c := range []error{notFound, locked, gone} {
+		spec, ok := errors.SpecOf(c)
+		so.True(ok)
+		specs = append(specs, spec)
+	}
+
+	doc, err := GenerateOAPI([]HandlerDesc{{
+		Method: "GET", Path: "/items/{id}", Func: testHandler,
+		Input: reflect.TypeFor[testInput](), Output: reflect.TypeFor[testOutput](),
+		ResponseStyle: negmarshal.StyleRaw,
+		Errors:        specs,
+	}}, hchi.OptionExtensions{})
+	so.NoError(err)
+	_, err = doc.Render()
+	so.NoError(err)
+
+	codes := operation(doc, "GET", "/items/{id}").Responses.Codes
+
+	rsp404 := codes.GetOrZero("404")
+	so.NotNil(rsp404)
+	problem := rsp404.Content.GetOrZero("application/problem+json").Schema.Schema()
+	so.Equal(problemSchemaRef, problem.AllOf[0].GetReference())
+	enum := problem.AllOf[1].Schema().Properties.GetOrZero("type").Schema().Enum
+	so.Len(enum, 2)
+	so.Equal("ITEM_GONE", enum[0].Value)
+	so.Equal("ITEM_NOT_FOUND", enum[1].Value)
+
+	problem = codes.GetOrZero("409").Content.GetOrZero("application/problem+json").Schema.Schema()
+	ext := problem.AllOf[1].Schema().Properties.GetOrZero("extensions").Schema()
+	detail := ext.Properties.GetOrZero("oapigen.conflictDetail")
+	so.NotNil(detail)
+	so.True(detail.IsReference())
+	_, found := doc.Components.Schemas.Get(strings.TrimPrefix(detail.GetReference(), "#/components/schemas/"))
+	so.True(found)
+}
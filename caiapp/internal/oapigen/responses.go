@@ -0,0 +1,193 @@
+package oapigen
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/http/ws"
+	"google.golang.org/protobuf/proto"
+)
+
+const problemSchemaName = "caisson.ProblemDetail"
+
+var problemSchemaRef = "#/components/schemas/" + problemSchemaName
+
+// problemSchema describes the RFC7807 problem document emitted by errmarshalhttp.
+func problemSchema() *base.Schema {
+	props := orderedmap.New[string, *base.SchemaProxy]()
+	props.Set("type", stringSchema("Error type; see the Coder's type"))
+	props.Set("title", stringSchema("User-facing error message"))
+	props.Set("status", base.CreateSchemaProxy(&base.Schema{Type: []string{"integer"}, Description: "HTTP status code"}))
+	props.Set("detail", stringSchema("Error details"))
+	props.Set("instance", stringSchema(""))
+	props.Set("extensions", base.CreateSchemaProxy(&base.Schema{
+		Type:                 []string{"object"},
+		AdditionalProperties: &base.DynamicValue[*base.SchemaProxy, bool]{N: 1, B: true},
+	}))
+	return &base.Schema{
+		Type:       []string{"object"},
+		Properties: props,
+		Required:   []string{"type", "title", "status"},
+	}
+}
+
+func stringSchema(description string) *base.SchemaProxy {
+	return base.CreateSchemaProxy(&base.Schema{Type: []string{"string"}, Description: description})
+}
+
+// ensureProblemSchema adds the problem document schema to the components.
+func ensureProblemSchema(doc *v3.Document) {
+	if doc.Components == nil {
+		doc.Components = &v3.Components{}
+	}
+	if doc.Components.Schemas == nil {
+		doc.Components.Schemas = orderedmap.New[string, *base.SchemaProxy]()
+	}
+	if _, ok := doc.Components.Schemas.Get(problemSchemaName); ok {
+		return
+	}
+	doc.Components.Schemas.Set(problemSchemaName, base.CreateSchemaProxy(problemSchema()))
+}
+
+// envelopeSchema wraps the data and error schemas into the {data, error, success} envelope.
+func envelopeSchema(data *base.SchemaProxy, errSchema *base.SchemaProxy) *base.SchemaProxy {
+	props := orderedmap.New[string, *base.SchemaProxy]()
+	props.Set("data", data)
+	props.Set("error", errSchema)
+	props.Set("success", base.CreateSchemaProxy(&base.Schema{Type: []string{"boolean"}}))
+	return base.CreateSchemaProxy(&base.Schema{
+		Type:       []string{"object"},
+		Properties: props,
+		Required:   []string{"data", "error", "success"},
+	})
+}
+
+func nullSchema() *base.SchemaProxy {
+	return base.CreateSchemaProxy(&base.Schema{Type: []string{"null"}})
+}
+
+func emptyObjectSchema() *base.SchemaProxy {
+	return base.CreateSchemaProxy(&base.Schema{Type: []string{"object"}})
+}
+
+// problemContent lists the error responses' formats for the problem schema.
+func problemContent(style negmarshal.Style, problem *base.SchemaProxy) *orderedmap.Map[string, *v3.MediaType] {
+	ret := orderedmap.New[string, *v3.MediaType]()
+	for _, c := range negmarshal.Codecs() {
+		_, valueOnly := c.(negmarshal.ValueOnlyCodec)
+		if valueOnly || style == negmarshal.StyleRaw {
+			ret.Set(c.ProblemContentType(), &v3.MediaType{Schema: problem})
+			continue
+		}
+		ret.Set(c.ContentType(), &v3.MediaType{Schema: envelopeSchema(nullSchema(), problem)})
+	}
+	return ret
+}
+
+// describeResponses reshapes the operation's responses according to the handler's response style,
+// and documents the error responses.
+var protoMessageType = reflect.TypeFor[proto.Message]()
+
+// encodes reports whether the codec is able to encode the handler's output of the type t.
+// The negotiator passes the values to the value-only codecs as is, so the protobuf codec
+// accepts the pointers to the messages but not the messages' values.
+func encodes(c negmarshal.Codec, t reflect.Type) bool {
+	vo, ok := c.(negmarshal.ValueOnlyCodec)
+	switch {
+	case !ok:
+		return true
+	case t == nil:
+		return false
+	case c.ContentType() == negmarshal.Protobuf().ContentType():
+		return t.Implements(protoMessageType)
+	}
+	return vo.CanEncode(reflect.Zero(t).Interface())
+}
+
+func describeResponses(doc *v3.Document, op *v3.Operation, d HandlerDesc) error {
+	ensureProblemSchema(doc)
+
+	if op.Responses == nil {
+		op.Responses = &v3.Responses{}
+	}
+	if op.Responses.Codes == nil {
+		op.Responses.Codes = orderedmap.New[string, *v3.Response]()
+	}
+
+	ok, found := op.Responses.Codes.Get("200")
+	if !found {
+		ok = &v3.Response{Description: "Successful response"}
+		op.Responses.Codes.Set("200", ok)
+	}
+
+	var dataSchema *base.SchemaProxy
+	if ok.Content != nil {
+		if mt, found := ok.Content.Get("application/json"); found {
+			dataSchema = mt.Schema
+		}
+	}
+	if dataSchema == nil {
+		// handlers without the output type respond with an empty object
+		dataSchema = emptyObjectSchema()
+	}
+
+	problem := base.CreateSchemaProxyRef(problemSchemaRef)
+
+	ok.Content = orderedmap.New[string, *v3.MediaType]()
+	errRsp := &v3.Response{
+		Description: "Error response",
+		Content:     problemContent(d.ResponseStyle, problem),
+	}
+
+	// every registered format is negotiable
+	for _, c := range negmarshal.Codecs() {
+		if _, valueOnly := c.(negmarshal.ValueOnlyCodec); valueOnly {
+			if encodes(c, d.Output) {
+				ok.Content.Set(c.ContentType(), &v3.MediaType{Schema: dataSchema})
+			}
+			continue
+		}
+
+		switch d.ResponseStyle {
+		case negmarshal.StyleRaw:
+			ok.Content.Set(c.ContentType(), &v3.MediaType{Schema: dataSchema})
+		default:
+			ok.Content.Set(c.ContentType(), &v3.MediaType{Schema: envelopeSchema(dataSchema, nullSchema())})
+		}
+	}
+
+	if d.Streaming {
+		// the streams' items are never enveloped
+		ok.Content = orderedmap.New[string, *v3.MediaType]()
+		ok.Content.Set(negmarshal.NDJSON().ContentType(), &v3.MediaType{Schema: dataSchema})
+		ok.Content.Set(httpbinding.ContentTypeSSE, &v3.MediaType{Schema: dataSchema})
+		ok.Description = "Stream of items, one per line for NDJSON or one per event for SSE; an error ends the stream with a problem document (the `error` event for SSE)"
+	}
+
+	op.Responses.Default = errRsp
+
+	if len(d.StatusCodes) > 0 {
+		op.Responses.Codes.Delete("200")
+		for _, code := range d.StatusCodes {
+			rsp := &v3.Response{Description: http.StatusText(code), Content: ok.Content}
+			if code == http.StatusNoContent || code == http.StatusNotModified {
+				rsp.Content = nil
+			}
+			if code == http.StatusSwitchingProtocols {
+				rsp.Content = nil
+				if d.WebSocket {
+					rsp.Description = "Upgraded to WebSocket; the messages' format is negotiated via the subprotocol: " + strings.Join(ws.Subprotocols(), ", ")
+				}
+			}
+			op.Responses.Codes.Set(strconv.Itoa(code), rsp)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package oapigen
+
+import (
+	"path"
+	"reflect"
+	"slices"
+
+	"github.com/pb33f/libopenapi/datamodel/high/base"
+	v3 "github.com/pb33f/libopenapi/datamodel/high/v3"
+	"github.com/pb33f/libopenapi/orderedmap"
+	"github.com/utrack/caisson-go/caiapp/internal/hchi"
+	"github.com/utrack/caisson-go/pkg/http/httpbinding"
+)
+
+var (
+	typeFile  = reflect.TypeFor[*httpbinding.File]()
+	typeFiles = reflect.TypeFor[[]*httpbinding.File]()
+)
+
+// describeUploads documents the request bodies of the inputs with httpbinding.File fields
+// as multipart/form-data, the files being binary strings.
+//
+// Their untagged fields are decoded from the body, so the body's schema consists of
+// the input schema's properties not extracted by httpin.
+func describeUploads(doc *v3.Document, handlers []HandlerDesc, ropts hchi.OptionExtensions) {
+	for _, d := range handlers {
+		if d.Input == nil || !hasFiles(d.Input) {
+			continue
+		}
+		t := d.Input
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		op := operation(doc, d.Method, path.Join(ropts.Prefix, d.Path))
+		in := inputSchema(doc, t)
+		if op == nil || in == nil || in.Properties == nil {
+			continue
+		}
+
+		form := &base.Schema{
+			Type:       []string{"object"},
+			Properties: orderedmap.New[string, *base.SchemaProxy](),
+		}
+		for prop := in.Properties.First(); prop != nil; prop = prop.Next() {
+			ps := prop.Value().Schema()
+			if ps == nil || extension(ps.Extensions, "x-httpin-in") != "" {
+				continue
+			}
+			if f, ok := t.FieldByName(extension(ps.Extensions, extFieldGoName)); ok {
+				switch f.Type {
+				case typeFile:
+					ps = binarySchema()
+				case typeFiles:
+					ps = &base.Schema{Type: []string{"array"}, Items: &base.DynamicValue[*base.SchemaProxy, bool]{A: base.CreateSchemaProxy(binarySchema())}}
+				}
+			}
+			form.Properties.Set(prop.Key(), base.CreateSchemaProxy(ps))
+			if slices.Contains(in.Required, prop.Key()) {
+				form.Required = append(form.Required, prop.Key())
+			}
+		}
+
+		op.RequestBody = &v3.RequestBody{Content: orderedmap.New[string, *v3.MediaType]()}
+		op.RequestBody.Content.Set("multipart/form-data", &v3.MediaType{Schema: base.CreateSchemaProxy(form)})
+	}
+}
+
+func binarySchema() *base.Schema {
+	return &base.Schema{Type: []string{"string"}, Format: "binary"}
+}
+
+// hasFiles reports whether the struct has the httpbinding.File fields.
+func hasFiles(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if ft := t.Field(i).Type; ft == typeFile || ft == typeFiles {
+			return true
+		}
+	}
+	return false
+}
+
+// inputSchema returns the component schema generated for the struct type.
+func inputSchema(doc *v3.Document, t reflect.Type) *base.Schema {
+	if doc.Components == nil || doc.Components.Schemas == nil {
+		return nil
+	}
+	key := t.PkgPath() + "." + t.Name()
+	for pair := doc.Components.Schemas.First(); pair != nil; pair = pair.Next() {
+		if s := pair.Value().Schema(); s != nil && goTypeKey(s.Extensions) == key {
+			return s
+		}
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package sdescbind
 
 import (
+	"net/http"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/utrack/caisson-go/caiapp/internal/oapigen"
+	"github.com/utrack/caisson-go/caiapp/internal/svcopt"
 	"github.com/utrack/caisson-go/errors"
 	"github.com/utrack/caisson-go/pkg/http/hhandler"
 	"github.com/utrack/caisson-go/pkg/http/httpbinding"
@@ -10,15 +13,31 @@ import (
 	"github.com/utrack/pontoon/sdesc"
 )
 
-func Bind(s sdesc.Service, h hhandler.Handler) ([]oapigen.HandlerDesc, error) {
+// Bind registers the service's handlers; style and bopts are the app's defaults.
+func Bind(s sdesc.Service, h hhandler.Handler, style negmarshal.Style, bopts ...httpbinding.Option) ([]oapigen.HandlerDesc, error) {
 	sconfig := sdesc.HandlerConfig{}
 	for _, opt := range s.ServiceOptions() {
 		opt(&sconfig)
 	}
+	opts, mws := svcopt.Extract(sconfig)
+	if opts.ResponseStyle != nil {
+		style = *opts.ResponseStyle
+	}
+	if opts.MaxBodySize != nil {
+		bopts = append(bopts, httpbinding.WithMaxBodySize(*opts.MaxBodySize))
+	}
+	if opts.DisallowUnknownFields != nil {
+		bopts = append(bopts, httpbinding.WithDisallowUnknownFields(*opts.DisallowUnknownFields))
+	}
+
 	b := &binder{
-		neg:     negmarshal.Default(),
-		h:       h,
-		sconfig: sconfig,
+		neg:         negmarshal.ForStyle(style),
+		style:       style,
+		bopts:       bopts,
+		h:           h,
+		mws:         mws,
+		errs:        opts.Errors,
+		handlerErrs: opts.HandlerErrors,
 	}
 	s.RegisterHTTP(b)
 
@@ -26,9 +45,15 @@ func Bind(s sdesc.Service, h hhandler.Handler) ([]oapigen.HandlerDesc, error) {
 }
 
 type binder struct {
-	neg     negmarshal.NegotiatedMarshalFunc
-	h       hhandler.Handler
-	sconfig sdesc.HandlerConfig
+	neg   negmarshal.NegotiatedMarshalFunc
+	style negmarshal.Style
+	bopts []httpbinding.Option
+	h     hhandler.Handler
+	mws   []func(http.Handler) http.Handler
+
+	// errs are the Coders declared for every handler, handlerErrs - for the specific ones
+	errs        []error
+	handlerErrs map[string][]error
 
 	handlerMeta []oapigen.HandlerDesc
 
@@ -38,21 +63,36 @@ type binder struct {
 var _ sdesc.HTTPRouter = (*binder)(nil)
 
 func (b *binder) MethodFunc(method, pattern string, hdl sdesc.RPCHandler) {
-	handler, meta, err := httpbinding.BindHTTPHandlerMeta(hdl, b.neg)
+	handler, meta, err := httpbinding.BindHTTPHandlerMeta(hdl, b.neg, b.bopts...)
 	if err != nil {
 		b.bindError = errors.Wrapd(err, "when binding HTTP handler", "method", method, "pattern", pattern)
 		return
 	}
 
-	mws := chi.Middlewares(b.sconfig.Middlewares())
+	var specs []errors.CoderSpec
+	for _, e := range append(append([]error(nil), b.errs...), b.handlerErrs[svcopt.HandlerKey(hdl)]...) {
+		spec, ok := errors.SpecOf(e)
+		if !ok {
+			b.bindError = errors.Errorf("declared error %q of %v %v is not a Coder", e.Error(), method, pattern)
+			return
+		}
+		specs = append(specs, spec)
+	}
+
+	mws := chi.Middlewares(b.mws)
 
 	b.h.MethodFunc(method, pattern, mws.HandlerFunc(handler.ServeHTTP).ServeHTTP)
 
 	b.handlerMeta = append(b.handlerMeta, oapigen.HandlerDesc{
-		Method: method,
-		Path:   pattern,
-		Func:   meta.NamedFunc,
-		Input:  meta.InputType,
-		Output: meta.OutputType,
+		Method:        method,
+		Path:          pattern,
+		Func:          meta.NamedFunc,
+		Input:         meta.InputType,
+		Output:        meta.OutputType,
+		StatusCodes:   meta.StatusCodes,
+		Streaming:     meta.Streaming,
+		WebSocket:     meta.WebSocket,
+		Errors:        specs,
+		ResponseStyle: b.style,
 	})
 }
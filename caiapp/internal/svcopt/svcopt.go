@@ -0,0 +1,82 @@
+// Package svcopt carries caiapp-specific options through the sdesc.ServiceOption's.
+//
+// sdesc.HandlerConfig holds nothing but middlewares, so the options are disguised as
+// marker middlewares; Extract tells them apart from the real ones by their code pointer,
+// without calling the real ones.
+package svcopt
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/pontoon/sdesc"
+)
+
+// Options are the caiapp-specific service options.
+type Options struct {
+	// ResponseStyle overrides the app's response style, if set.
+	ResponseStyle *negmarshal.Style
+	// MaxBodySize overrides the app's request body size limit, if set.
+	MaxBodySize *int64
+	// DisallowUnknownFields overrides the app's strict decoding mode, if set.
+	DisallowUnknownFields *bool
+	// Errors are the Coders any of the service's handlers may return.
+	Errors []error
+	// HandlerErrors are the Coders the handlers may return, by HandlerKey.
+	HandlerErrors map[string][]error
+}
+
+// HandlerKey identifies the handler function or method, regardless of its receiver.
+func HandlerKey(h any) string {
+	return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+}
+
+type Option func(*Options)
+
+// probe is passed to the marker middlewares by Extract to collect their options.
+type probe struct {
+	opts []Option
+}
+
+func (p *probe) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+// Service wraps the option as an sdesc.ServiceOption.
+func Service(o Option) sdesc.ServiceOption {
+	return sdesc.WithMiddlewares((&marker{opt: o}).middleware)
+}
+
+// marker is the option's marker middleware; it is a no-op if applied by anything but Extract.
+type marker struct {
+	opt Option
+}
+
+func (m *marker) middleware(next http.Handler) http.Handler {
+	if p, ok := next.(*probe); ok {
+		p.opts = append(p.opts, m.opt)
+	}
+	return next
+}
+
+// markerPC is the code pointer shared by the markers' method values.
+var markerPC = reflect.ValueOf((&marker{}).middleware).Pointer()
+
+// Extract splits the service's config into the caiapp-specific options and the real middlewares.
+// Only the marker middlewares are called.
+func Extract(cfg sdesc.HandlerConfig) (Options, []func(http.Handler) http.Handler) {
+	var ret Options
+	var mws []func(http.Handler) http.Handler
+	for _, mw := range cfg.Middlewares() {
+		if reflect.ValueOf(mw).Pointer() != markerPC {
+			mws = append(mws, mw)
+			continue
+		}
+		p := &probe{}
+		mw(p)
+		for _, o := range p.opts {
+			o(&ret)
+		}
+	}
+	return ret, mws
+}
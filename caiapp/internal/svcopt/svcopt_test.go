@@ -0,0 +1,33 @@
+package svcopt
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/pontoon/sdesc"
+)
+
+func TestExtract(t *testing.T) {
+	so := require.New(t)
+
+	calls := 0
+	userMW := func(next http.Handler) http.Handler {
+		calls++
+		return next
+	}
+	size := int64(10)
+
+	cfg := sdesc.HandlerConfig{}
+	for _, opt := range []sdesc.ServiceOption{
+		sdesc.WithMiddlewares(userMW),
+		Service(func(o *Options) { o.MaxBodySize = &size }),
+	} {
+		opt(&cfg)
+	}
+
+	opts, mws := Extract(cfg)
+	so.Equal(&size, opts.MaxBodySize)
+	so.Len(mws, 1)
+	so.Zero(calls)
+}
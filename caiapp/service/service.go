@@ -0,0 +1,56 @@
+// Package service provides caiapp-specific options for the sdesc services.
+//
+// Return them from your service's ServiceOptions() along with the generic sdesc ones.
+package service
+
+import (
+	"github.com/utrack/caisson-go/caiapp/internal/svcopt"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/pontoon/sdesc"
+)
+
+// WithResponseStyle overrides the app's response style (see handler.WithResponseStyle) for a single service.
+func WithResponseStyle(style negmarshal.Style) sdesc.ServiceOption {
+	return svcopt.Service(func(o *svcopt.Options) {
+		o.ResponseStyle = &style
+	})
+}
+
+// WithMaxBodySize overrides the app's request body size limit for a single service; 0 disables the limit.
+func WithMaxBodySize(size int64) sdesc.ServiceOption {
+	return svcopt.Service(func(o *svcopt.Options) {
+		o.MaxBodySize = &size
+	})
+}
+
+// WithDisallowUnknownFields overrides the app's strict decoding mode for a single service.
+//
+// If set, the request bodies with the fields unknown to the handlers' inputs are rejected with 400.
+func WithDisallowUnknownFields(disallow bool) sdesc.ServiceOption {
+	return svcopt.Service(func(o *svcopt.Options) {
+		o.DisallowUnknownFields = &disallow
+	})
+}
+
+// WithErrors declares the errors any of the service's handlers may return, for the OpenAPI spec.
+// The errors should be Coders or CoderDetailers; see errors.SpecOf.
+func WithErrors(coders ...error) sdesc.ServiceOption {
+	return svcopt.Service(func(o *svcopt.Options) {
+		o.Errors = append(o.Errors, coders...)
+	})
+}
+
+// WithHandlerErrors declares the errors the handler may return, for the OpenAPI spec:
+//
+//	service.WithHandlerErrors(s.GetItem, ErrItemNotFound, ErrItemLocked)
+//
+// The errors should be Coders or CoderDetailers; see errors.SpecOf.
+func WithHandlerErrors(h sdesc.RPCHandler, coders ...error) sdesc.ServiceOption {
+	return svcopt.Service(func(o *svcopt.Options) {
+		if o.HandlerErrors == nil {
+			o.HandlerErrors = map[string][]error{}
+		}
+		key := svcopt.HandlerKey(h)
+		o.HandlerErrors[key] = append(o.HandlerErrors[key], coders...)
+	})
+}
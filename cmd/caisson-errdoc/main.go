@@ -0,0 +1,74 @@
+// Command caisson-errdoc generates the markdown error reference from the app's error catalogue.
+//
+// The catalogue is read from the caiapp debug port's /debug/errors.json, or from a file saved from it:
+//
+//	caisson-errdoc -src http://localhost:8082/debug/errors.json -out docs/errors.md
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/errcatalog"
+)
+
+func main() {
+	src := flag.String("src", "http://localhost:8082/debug/errors.json", "catalogue's URL or file path; - reads stdin")
+	out := flag.String("out", "-", "output file path; - writes to stdout")
+	flag.Parse()
+
+	if err := run(*src, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "caisson-errdoc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(src, out string) error {
+	r, err := open(src)
+	if err != nil {
+		return errors.Wrapf(err, "when opening %v", src)
+	}
+	defer r.Close()
+
+	var entries []errors.CatalogueEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return errors.Wrap(err, "when decoding the catalogue")
+	}
+
+	w := io.WriteCloser(os.Stdout)
+	if out != "-" {
+		w, err = os.Create(out)
+		if err != nil {
+			return err
+		}
+	}
+	if err := errcatalog.Markdown(w, entries); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func open(src string) (io.ReadCloser, error) {
+	switch {
+	case src == "-":
+		return io.NopCloser(os.Stdin), nil
+	case strings.HasPrefix(src, "http://"), strings.HasPrefix(src, "https://"):
+		rsp, err := http.Get(src)
+		if err != nil {
+			return nil, err
+		}
+		if rsp.StatusCode != http.StatusOK {
+			rsp.Body.Close()
+			return nil, errors.Errorf("unexpected status %v", rsp.Status)
+		}
+		return rsp.Body, nil
+	}
+	return os.Open(src)
+}
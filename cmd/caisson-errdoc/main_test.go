@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/errcatalog"
+)
+
+func TestRun(t *testing.T) {
+	so := require.New(t)
+	errors.Register(errors.NewCoder("TEST_ERRDOC_GONE").WithHTTPCode(410).WithMessage("gone"))
+
+	srv := httptest.NewServer(errcatalog.Handler())
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "errors.md")
+	so.NoError(run(srv.URL, out))
+
+	buf, err := os.ReadFile(out)
+	so.NoError(err)
+	so.Contains(string(buf), "| `TEST_ERRDOC_GONE` | 410 Gone | gone |  |")
+
+	so.Error(run(filepath.Join(t.TempDir(), "missing.json"), out))
+}
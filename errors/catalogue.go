@@ -0,0 +1,129 @@
+package errors
+
+import (
+	"cmp"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CatalogueEntry describes a Coder registered in the catalogue.
+type CatalogueEntry struct {
+	Type     string `json:"type"`
+	HTTPCode int    `json:"http_code"`
+	Message  string `json:"message"`
+	// DetailType is the CoderDetailer's details type, like users.ConflictDetail;
+	// the details are listed under this key in the problem's extensions. Empty for the Coders.
+	DetailType string `json:"detail_type,omitempty"`
+	// Package is the import path of the package which registered the Coder.
+	Package string `json:"package"`
+}
+
+var catalogue struct {
+	sync.Mutex
+	entries []CatalogueEntry
+	// coders are the registered Coders, in the entries' order
+	coders []error
+}
+
+// Register adds the Coder or CoderDetailer to the process-wide catalogue and returns it:
+//
+//	var ErrNotFound = errors.Register(errors.NewCoder("NOT_FOUND").WithHTTPCode(404).WithMessage("not found"))
+//
+// The catalogue is served on caiapp's debug port; see CheckCatalogue for the startup checks.
+// Register panics if c is neither a Coder nor a CoderDetailer.
+func Register[C error](c C) C {
+	spec, ok := SpecOf(c)
+	if !ok {
+		panic(fmt.Sprintf("errors.Register: %T is not a Coder", c))
+	}
+
+	e := CatalogueEntry{
+		Type:     spec.Type(),
+		HTTPCode: spec.HTTPCode(),
+		Message:  spec.Message(),
+	}
+	if spec.DetailType != nil {
+		e.DetailType = spec.DetailType.String()
+	}
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		e.Package = funcPackage(runtime.FuncForPC(pc).Name())
+	}
+
+	catalogue.Lock()
+	defer catalogue.Unlock()
+	if !slices.Contains(catalogue.entries, e) {
+		catalogue.entries = append(catalogue.entries, e)
+		catalogue.coders = append(catalogue.coders, c)
+	}
+	return c
+}
+
+// registered returns the first Coder registered with the type and HTTP code.
+func registered(typ string, httpCode int) (error, bool) {
+	catalogue.Lock()
+	defer catalogue.Unlock()
+	for i, e := range catalogue.entries {
+		if e.Type == typ && e.HTTPCode == httpCode {
+			return catalogue.coders[i], true
+		}
+	}
+	return nil, false
+}
+
+// funcPackage extracts the package's path from the function's name,
+// like github.com/a/b from github.com/a/b.(*T).Method or github.com/a/b.init.
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// Catalogue returns the registered Coders sorted by their types.
+func Catalogue() []CatalogueEntry {
+	catalogue.Lock()
+	ret := slices.Clone(catalogue.entries)
+	catalogue.Unlock()
+
+	slices.SortFunc(ret, func(a, b CatalogueEntry) int {
+		return cmp.Or(
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.HTTPCode, b.HTTPCode),
+			cmp.Compare(a.Package, b.Package),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+	return ret
+}
+
+// CheckCatalogue reports the types registered with the conflicting HTTP codes.
+// caiapp runs it at startup.
+func CheckCatalogue() error {
+	entries := Catalogue()
+
+	var conflicts []string
+	for i := 0; i < len(entries); {
+		j := i + 1
+		for j < len(entries) && entries[j].Type == entries[i].Type {
+			j++
+		}
+		group := entries[i:j]
+		// sorted by HTTP code within the type
+		if group[0].HTTPCode != group[len(group)-1].HTTPCode {
+			defs := make([]string, 0, len(group))
+			for _, e := range group {
+				defs = append(defs, fmt.Sprintf("%d in %v", e.HTTPCode, e.Package))
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%v (%v)", group[0].Type, strings.Join(defs, ", ")))
+		}
+		i = j
+	}
+	if len(conflicts) > 0 {
+		return Errorf("error types registered with conflicting HTTP codes: %v", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
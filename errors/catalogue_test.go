@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type catalogueDetail struct {
+	Reason string
+}
+
+func TestRegister(t *testing.T) {
+	so := require.New(t)
+
+	notFound := Register(NewCoder("TEST_CATALOGUE_NOT_FOUND").WithHTTPCode(404).WithMessage("not found"))
+	Register(NewCoderDetailer[catalogueDetail]("TEST_CATALOGUE_LOCKED").WithHTTPCode(409))
+	so.Equal(404, Code(notFound.Wrap(New("x"))).HTTPCode())
+
+	var found []CatalogueEntry
+	for _, e := range Catalogue() {
+		if e.Type == "TEST_CATALOGUE_LOCKED" || e.Type == "TEST_CATALOGUE_NOT_FOUND" {
+			found = append(found, e)
+		}
+	}
+	so.Equal([]CatalogueEntry{
+		{Type: "TEST_CATALOGUE_LOCKED", HTTPCode: 409, DetailType: "errors.catalogueDetail", Package: "github.com/utrack/caisson-go/errors"},
+		{Type: "TEST_CATALOGUE_NOT_FOUND", HTTPCode: 404, Message: "not found", Package: "github.com/utrack/caisson-go/errors"},
+	}, found)
+	so.NoError(CheckCatalogue())
+
+	Register(NewCoder("TEST_CATALOGUE_NOT_FOUND").WithHTTPCode(410))
+	so.ErrorContains(CheckCatalogue(), "TEST_CATALOGUE_NOT_FOUND (404 in github.com/utrack/caisson-go/errors, 410 in github.com/utrack/caisson-go/errors)")
+
+	so.Panics(func() { Register(New("plain")) })
+}
@@ -1,40 +1,160 @@
 package errors
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
 )
 
-// Coder is a static struct which enriches passing errors with HTTP codes and user messages.
+// Coder is a static struct which enriches passing errors with HTTP/gRPC codes and user messages.
 type Coder interface {
 	WithType(typ string) Coder
 	WithMessage(userMessage string) Coder
 	WithMessagef(format string, args ...any) Coder
 	WithHTTPCode(httpCode int) Coder
+	// WithGRPCCode sets the gRPC code explicitly; it is derived from the HTTP code otherwise.
+	WithGRPCCode(code codes.Code) Coder
+	// Retryable marks the errors as safe to retry; see IsRetryable.
+	Retryable() Coder
+	// RetryAfter marks the errors as retryable after the delay; it is sent as the Retry-After header.
+	RetryAfter(d time.Duration) Coder
+	// WithSeverity sets the severity explicitly; it is derived from the HTTP code otherwise.
+	WithSeverity(s Severity) Coder
 	Wrap(cause error) error
 	Error() string
 }
 
 // Coded is an error instance enriched with HTTP codes and user messages.
+//
+// The Coded errors of this package's Coders implement CodedPolicy as well; see PolicyOf.
 type Coded interface {
 	HTTPCode() int
 	Message() string
 	Type() string
 }
 
+// CodedPolicy is the Coded carrying the error's handling policy: its gRPC code,
+// retries and severity. It's an optional interface, so that the Coded implementations
+// outside of this package keep working; use PolicyOf to read the policy of any Coded.
+type CodedPolicy interface {
+	Coded
+	GRPCCode() codes.Code
+	// Retryable reports whether the Coder was marked via Retryable or RetryAfter.
+	Retryable() bool
+	// RetryAfter returns the delay set via Coder.RetryAfter, or zero.
+	RetryAfter() time.Duration
+	// Severity returns the severity set via WithSeverity, or the one derived from the HTTP code.
+	Severity() Severity
+}
+
+// PolicyOf returns the Coded's policy. The Coded errors not implementing CodedPolicy get
+// the defaults derived from their HTTP code: see GRPCCodeFromHTTP and SeverityFromHTTP;
+// they aren't retryable by themselves.
+//
+// The Coded should be non-nil.
+func PolicyOf(c Coded) CodedPolicy {
+	if p, ok := c.(CodedPolicy); ok {
+		return p
+	}
+	return defaultPolicy{Coded: c}
+}
+
+type defaultPolicy struct {
+	Coded
+}
+
+func (p defaultPolicy) GRPCCode() codes.Code      { return GRPCCodeFromHTTP(p.HTTPCode()) }
+func (p defaultPolicy) Retryable() bool           { return false }
+func (p defaultPolicy) RetryAfter() time.Duration { return 0 }
+func (p defaultPolicy) Severity() Severity        { return SeverityFromHTTP(p.HTTPCode()) }
+
+// Code returns the error's Coded, or nil if there's none.
+//
+// Within the error chain the outermost Coded wins, so that the callers may re-code the errors they wrap.
+// Among the branches of errors.Join and other Unwrap() []error errors, the Coded with
+// the highest HTTP code wins (so 5xx take precedence over 4xx); the first branch wins a tie.
+// KeyedData and the CoderDetailers' ExtractDetail resolve the values in the same order, see find.
 func Code(err error) Coded {
-	if err == nil {
-		return nil
+	if d, ok := find(err, isCoded).(Detailed[Coded]); ok {
+		return d.Value()
 	}
-	var ret Detailed[Coded]
-	ok := As(err, &ret)
-	if ok {
-		return ret.Value()
+	return nil
+}
+
+func isCoded(err error) bool {
+	_, ok := err.(Detailed[Coded])
+	return ok
+}
+
+// find returns the first error of the tree that matches, or nil.
+// It follows the error chain outermost-first; the branches of errors.Join and other
+// Unwrap() []error errors are visited in the order of their Codes' HTTP codes, highest first,
+// so that the values are looked up in the branch that decides the error's Code.
+func find(err error, match func(error) bool) error {
+	for err != nil {
+		if match(err) {
+			return err
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range byHTTPCode(u.Unwrap()) {
+				if ret := find(e, match); ret != nil {
+					return ret
+				}
+			}
+			return nil
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			return nil
+		}
 	}
 	return nil
 }
 
+// byHTTPCode sorts the branches by their Codes' HTTP codes, highest first;
+// the uncoded branches go last, and the ties keep their order.
+func byHTTPCode(errs []error) []error {
+	httpCodes := make(map[int]int, len(errs))
+	for i, e := range errs {
+		if c := Code(e); c != nil {
+			httpCodes[i] = c.HTTPCode()
+		}
+	}
+	idx := make([]int, len(errs))
+	for i := range idx {
+		idx[i] = i
+	}
+	slices.SortStableFunc(idx, func(a, b int) int { return cmp.Compare(httpCodes[b], httpCodes[a]) })
+
+	ret := make([]error, len(errs))
+	for i, j := range idx {
+		ret[i] = errs[j]
+	}
+	return ret
+}
+
+// CoderSpec describes the errors produced by a Coder or CoderDetailer.
+type CoderSpec struct {
+	Coded
+	// DetailType is the type of the CoderDetailer's details; nil for the Coders.
+	DetailType reflect.Type
+}
+
+// SpecOf returns the spec of the Coder or CoderDetailer; ok is false for any other error.
+func SpecOf(c error) (spec CoderSpec, ok bool) {
+	s, ok := c.(interface{ spec() CoderSpec })
+	if !ok {
+		return CoderSpec{}, false
+	}
+	return s.spec(), true
+}
+
 func NewCoder(typ string) Coder {
 	return coder{data: coded{
 		Typ:         typ,
@@ -81,6 +201,39 @@ func (c coder) WithHTTPCode(httpCode int) Coder {
 	}
 }
 
+func (c coder) WithGRPCCode(code codes.Code) Coder {
+	d := c.data
+	d.GrpcCode = code
+	return coder{
+		data: d,
+	}
+}
+
+func (c coder) Retryable() Coder {
+	d := c.data
+	d.Retry = true
+	return coder{
+		data: d,
+	}
+}
+
+func (c coder) RetryAfter(delay time.Duration) Coder {
+	d := c.data
+	d.Retry = true
+	d.RetryDelay = delay
+	return coder{
+		data: d,
+	}
+}
+
+func (c coder) WithSeverity(s Severity) Coder {
+	d := c.data
+	d.Sev = s
+	return coder{
+		data: d,
+	}
+}
+
 func (c coder) Wrap(cause error) error {
 	d := c.data
 	return DetailWith[Coded](cause, d)
@@ -99,6 +252,10 @@ func (c coder) Is(target error) bool {
 	return errors.As(target, &t) && t.data == c.data
 }
 
+func (c coder) spec() CoderSpec {
+	return CoderSpec{Coded: c.data}
+}
+
 var coderType = reflect.TypeFor[Coded]()
 
 func (c coder) AsErrorBag() (reflect.Type, Coded) {
@@ -106,12 +263,18 @@ func (c coder) AsErrorBag() (reflect.Type, Coded) {
 }
 
 type coded struct {
-	HttpCode    int    `json:"http_code"`
-	Typ         string `json:"type"`
-	UserMessage string `json:"user_message"`
+	HttpCode int `json:"http_code"`
+	// GrpcCode is zero (codes.OK) if unset.
+	GrpcCode    codes.Code    `json:"grpc_code,omitempty"`
+	Typ         string        `json:"type"`
+	UserMessage string        `json:"user_message"`
+	Retry       bool          `json:"retryable,omitempty"`
+	RetryDelay  time.Duration `json:"retry_after,omitempty"`
+	// Sev is zero if unset.
+	Sev Severity `json:"severity,omitempty"`
 }
 
-var _ Coded = coded{}
+var _ CodedPolicy = coded{}
 
 func (c coded) HTTPCode() int {
 	if c.HttpCode == 0 {
@@ -120,6 +283,81 @@ func (c coded) HTTPCode() int {
 	return c.HttpCode
 }
 
+// GRPCCode returns the code set via WithGRPCCode, or the one
+// corresponding to the HTTP code.
+func (c coded) GRPCCode() codes.Code {
+	if c.GrpcCode != codes.OK {
+		return c.GrpcCode
+	}
+	return GRPCCodeFromHTTP(c.HTTPCode())
+}
+
+// GRPCCodeFromHTTP maps the HTTP status to the gRPC code as described in google.rpc.Code.
+// Unlisted 4xx statuses map to FailedPrecondition, the rest - to Unknown.
+func GRPCCodeFromHTTP(httpCode int) codes.Code {
+	switch httpCode {
+	case 400:
+		return codes.InvalidArgument
+	case 401:
+		return codes.Unauthenticated
+	case 403:
+		return codes.PermissionDenied
+	case 404:
+		return codes.NotFound
+	case 409:
+		return codes.AlreadyExists
+	case 412:
+		return codes.FailedPrecondition
+	case 416:
+		return codes.OutOfRange
+	case 429:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case 500:
+		return codes.Internal
+	case 501:
+		return codes.Unimplemented
+	case 503:
+		return codes.Unavailable
+	case 504:
+		return codes.DeadlineExceeded
+	}
+	if httpCode >= 400 && httpCode < 500 {
+		return codes.FailedPrecondition
+	}
+	return codes.Unknown
+}
+
+// HTTPCodeFromGRPC maps the gRPC code to the HTTP status as described in google.rpc.Code.
+func HTTPCodeFromGRPC(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return 200
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return 400
+	case codes.DeadlineExceeded:
+		return 504
+	case codes.NotFound:
+		return 404
+	case codes.AlreadyExists, codes.Aborted:
+		return 409
+	case codes.PermissionDenied:
+		return 403
+	case codes.Unauthenticated:
+		return 401
+	case codes.ResourceExhausted:
+		return 429
+	case codes.Unimplemented:
+		return 501
+	case codes.Unavailable:
+		return 503
+	}
+	return 500
+}
+
 func (c coded) Message() string {
 	return c.UserMessage
 }
@@ -128,6 +366,21 @@ func (c coded) Type() string {
 	return c.Typ
 }
 
+func (c coded) Retryable() bool {
+	return c.Retry
+}
+
+func (c coded) RetryAfter() time.Duration {
+	return c.RetryDelay
+}
+
+func (c coded) Severity() Severity {
+	if c.Sev != 0 {
+		return c.Sev
+	}
+	return SeverityFromHTTP(c.HTTPCode())
+}
+
 func (c coded) Is(target error) bool {
 	var t Coded
 	return errors.As(target, &t) && t.Type() == c.Typ && t.HTTPCode() == c.HttpCode && t.Message() == c.UserMessage
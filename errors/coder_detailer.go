@@ -1,5 +1,12 @@
 package errors
 
+import (
+	"reflect"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
 // CoderDetailer is a Coder that enriches errors with typed details.
 //
 // See Coder for the general description.
@@ -8,11 +15,16 @@ type CoderDetailer[T comparable] interface {
 	WithMessage(userMessage string) CoderDetailer[T]
 	WithMessagef(format string, args ...any) CoderDetailer[T]
 	WithHTTPCode(httpCode int) CoderDetailer[T]
+	WithGRPCCode(code codes.Code) CoderDetailer[T]
+	Retryable() CoderDetailer[T]
+	RetryAfter(d time.Duration) CoderDetailer[T]
+	WithSeverity(s Severity) CoderDetailer[T]
 	Wrap(cause error, details T) error
 
 	Error() string
 
 	// ExtractDetail extracts the embedded details from the error instance decorated via Wrap().
+	// Within errors.Join the details of the branch holding the error's Code win, see Code.
 	ExtractDetail(err error) *T
 }
 
@@ -54,18 +66,50 @@ func (c coderDetailer[T]) WithHTTPCode(httpCode int) CoderDetailer[T] {
 	}
 }
 
+func (c coderDetailer[T]) WithGRPCCode(code codes.Code) CoderDetailer[T] {
+	return coderDetailer[T]{
+		c.coder.WithGRPCCode(code),
+	}
+}
+
+func (c coderDetailer[T]) Retryable() CoderDetailer[T] {
+	return coderDetailer[T]{
+		c.coder.Retryable(),
+	}
+}
+
+func (c coderDetailer[T]) RetryAfter(d time.Duration) CoderDetailer[T] {
+	return coderDetailer[T]{
+		c.coder.RetryAfter(d),
+	}
+}
+
+func (c coderDetailer[T]) WithSeverity(s Severity) CoderDetailer[T] {
+	return coderDetailer[T]{
+		c.coder.WithSeverity(s),
+	}
+}
+
 func (c coderDetailer[T]) Error() string {
 	return c.coder.Error()
 }
 
+func (c coderDetailer[T]) spec() CoderSpec {
+	ret, _ := SpecOf(c.coder)
+	ret.DetailType = reflect.TypeFor[T]()
+	return ret
+}
+
 func (c coderDetailer[T]) Wrap(cause error, details T) error {
 	// TODO use error type instead of T's reflect type
-	return DetailWith(c.coder.Wrap(cause), details)
+	return publicDetailWith(c.coder.Wrap(cause), details)
 }
 
 func (c coderDetailer[T]) ExtractDetail(err error) *T {
-	var d Detailed[T]
-	ok := As(err, &d)
+	d, ok := find(err, func(err error) bool {
+		_, ok := err.(Detailed[T])
+		return ok
+	}).(Detailed[T])
 	if ok {
 		ret := d.Value()
 		return &ret
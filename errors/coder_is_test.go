@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 )
 
 func TestCoderIs(t *testing.T) {
@@ -32,3 +33,90 @@ func TestCoderIs__wrapInMiddle(t *testing.T) {
 	err := Wrap(code, "some middle error")
 	so.True(errors.Is(err, code))
 }
+
+func TestCoderGRPCCode(t *testing.T) {
+	so := require.New(t)
+
+	so.Equal(codes.NotFound, PolicyOf(Code(NewCoder("nf").WithHTTPCode(404).Wrap(New("x")))).GRPCCode())
+	so.Equal(codes.Internal, PolicyOf(Code(NewCoder("int").Wrap(New("x")))).GRPCCode())
+
+	explicit := NewCoder("nf").WithHTTPCode(404).WithGRPCCode(codes.Aborted)
+	err := explicit.Wrap(New("x"))
+	so.Equal(codes.Aborted, PolicyOf(Code(err)).GRPCCode())
+	so.True(Is(err, explicit))
+	so.False(Is(err, NewCoder("nf").WithHTTPCode(404)))
+}
+
+func TestCodeJoin(t *testing.T) {
+	so := require.New(t)
+
+	notFound := NewCoder("nf").WithHTTPCode(404)
+	unavailable := NewCoder("down").WithHTTPCode(503)
+	conflict := NewCoder("conflict").WithHTTPCode(409)
+
+	err := Join(notFound.Wrap(New("a")), Wrap(unavailable.Wrap(New("b")), "item b"), conflict.Wrap(New("c")))
+	so.Equal("down", Code(err).Type())
+	so.Equal("nf", Code(Join(New("plain"), notFound.Wrap(New("a")), NewCoder("nf2").WithHTTPCode(404).Wrap(New("b")))).Type())
+	so.Nil(Code(Join(New("a"), New("b"))))
+
+	// the outermost Coded of the chain wins regardless of the codes
+	so.Equal("nf", Code(notFound.Wrap(Wrap(err, "batch"))).Type())
+}
+
+func TestCodeJoin_details(t *testing.T) {
+	so := require.New(t)
+
+	type item struct{ ID string }
+	invalid := NewCoderDetailer[item]("invalid").WithHTTPCode(400)
+	locked := NewCoderDetailer[item]("locked").WithHTTPCode(423)
+
+	err := Join(
+		WithKeyedData(invalid.Wrap(New("a"), item{ID: "a1"}), "attempt", 1),
+		WithKeyedData(locked.Wrap(New("b"), item{ID: "b2"}), "attempt", 2),
+	)
+	so.Equal("locked", Code(err).Type())
+	so.Equal(&item{ID: "b2"}, invalid.ExtractDetail(err))
+	attempt, ok := KeyedData[string, int](err, "attempt")
+	so.True(ok)
+	so.Equal(2, attempt)
+
+	// the uncoded branches go last
+	err = Join(WithKeyedData(New("plain"), "attempt", 0), err)
+	attempt, _ = KeyedData[string, int](err, "attempt")
+	so.Equal(2, attempt)
+}
+
+// foreignCoded is a Coded implemented outside of the Coders, without the CodedPolicy.
+type foreignCoded struct{}
+
+func (foreignCoded) HTTPCode() int   { return 503 }
+func (foreignCoded) Message() string { return "down" }
+func (foreignCoded) Type() string    { return "DOWN" }
+
+func TestPolicyOf(t *testing.T) {
+	so := require.New(t)
+
+	p := PolicyOf(foreignCoded{})
+	so.Equal(codes.Unavailable, p.GRPCCode())
+	so.Equal(SeverityCritical, p.Severity())
+	so.False(p.Retryable())
+	so.Zero(p.RetryAfter())
+
+	err := DetailWith[Coded](New("x"), foreignCoded{})
+	so.Equal("DOWN", Code(err).Type())
+	so.Equal(SeverityCritical, SeverityOf(err))
+	so.True(IsRetryable(err))
+	_, ok := RetryAfter(err)
+	so.False(ok)
+
+	so.Equal(codes.Aborted, PolicyOf(Code(NewCoder("x").WithGRPCCode(codes.Aborted).Wrap(New("x")))).GRPCCode())
+}
+
+func TestSeverity(t *testing.T) {
+	so := require.New(t)
+
+	so.Equal(SeverityExpected, SeverityOf(NewCoder("nf").WithHTTPCode(404).Wrap(New("x"))))
+	so.Equal(SeverityCritical, SeverityOf(NewCoder("int").Wrap(New("x"))))
+	so.Equal(SeverityCritical, SeverityOf(New("x")))
+	so.Equal(SeverityWarning, SeverityOf(NewCoder("nf").WithHTTPCode(404).WithSeverity(SeverityWarning).Wrap(New("x"))))
+}
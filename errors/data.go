@@ -2,6 +2,7 @@ package errors
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/utrack/caisson-go/levels/level3/errorbag"
 )
@@ -20,11 +21,31 @@ func WithKeyedData[K comparable, T comparable](err error, key K, value T) error
 	return errorbag.With(err, key, value)
 }
 
+// WithPublicData is an equivalent of WithKeyedData, but the data is marked as public:
+// the error marshalers send it to the API clients, while the rest of the keyed data
+// stays in the logs and traces.
+//
+// The CoderDetailers' details are public; the Coded itself is not, the problems carry its type,
+// message and status already.
+func WithPublicData[K comparable, T comparable](err error, key K, value T) error {
+	return errorbag.WithPublic(err, key, value)
+}
+
 // KeyedData retrieves the value associated with the given key.
 //
 // Returns (zero value, false) if the key is not found.
+//
+// Unlike errorbag.Get, the branches of errors.Join are searched in the order Code resolves them:
+// the value comes from the branch of the winning Coded first.
 func KeyedData[K comparable, T comparable](err error, key K) (T, bool) {
-	return errorbag.Get[K, T](err, key)
+	if bag, ok := find(err, func(err error) bool {
+		bag, ok := err.(errorbag.Bag[K, T])
+		return ok && bag.Key() == key
+	}).(errorbag.Bag[K, T]); ok {
+		return bag.Value(), true
+	}
+	var zero T
+	return zero, false
 }
 
 func Wrapd(cause error, msg string, kvs ...any) error {
@@ -40,3 +61,19 @@ func Wrapd(cause error, msg string, kvs ...any) error {
 
 	return cause
 }
+
+var exposeInternal atomic.Bool
+
+// SetExposeInternal controls whether the error marshalers send the internal error details
+// to the API clients: the wrapped errors' text and the keyed data not marked public.
+//
+// The details are hidden by default; caisson turns them on in the development mode (see plconfig).
+func SetExposeInternal(expose bool) {
+	exposeInternal.Store(expose)
+}
+
+// ExposeInternal reports whether the internal error details are sent to the API clients;
+// see SetExposeInternal.
+func ExposeInternal() bool {
+	return exposeInternal.Load()
+}
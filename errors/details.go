@@ -23,6 +23,14 @@ func DetailWith[T comparable](err error, value T) error {
 	return WithKeyedData(err, typeName, value)
 }
 
+// publicDetailWith is an equivalent of DetailWith, but the detail is public; see WithPublicData.
+func publicDetailWith[T comparable](err error, value T) error {
+	if err == nil {
+		return nil
+	}
+	return WithPublicData(err, reflect.TypeFor[T](), value)
+}
+
 // Detailed is an interface for errors enriched with typed details.
 type Detailed[T comparable] interface {
 	error
@@ -0,0 +1,40 @@
+package errors
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// IsRetryable reports whether the operation failed with the error is safe to retry:
+//   - the error's Coded is marked via Coder.Retryable or Coder.RetryAfter,
+//     or its HTTP code is 429 Too Many Requests or 503 Service Unavailable;
+//   - the error is a net.Error timeout or context.DeadlineExceeded.
+//
+// context.Canceled is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || Is(err, context.Canceled) {
+		return false
+	}
+	if c := Code(err); c != nil {
+		if PolicyOf(c).Retryable() || c.HTTPCode() == http.StatusTooManyRequests || c.HTTPCode() == http.StatusServiceUnavailable {
+			return true
+		}
+	}
+	var ne net.Error
+	if As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return Is(err, context.DeadlineExceeded)
+}
+
+// RetryAfter returns the delay set via Coder.RetryAfter on the error's Coded; ok is false if there's none.
+func RetryAfter(err error) (d time.Duration, ok bool) {
+	c := Code(err)
+	if c == nil {
+		return 0, false
+	}
+	d = PolicyOf(c).RetryAfter()
+	return d, d > 0
+}
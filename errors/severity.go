@@ -0,0 +1,83 @@
+package errors
+
+import (
+	"log/slog"
+)
+
+// Severity tells the expected errors, like 404 Not Found, from the real failures.
+// It drives the platform's handling of the errors: the level of their logs, the spans' status
+// and the errors counter.
+type Severity int
+
+const (
+	// SeverityExpected errors are a part of the normal operation, like the clients' mistakes.
+	// They are logged with the Debug level and don't fail the spans.
+	SeverityExpected Severity = iota + 1
+	// SeverityWarning errors are worth looking into, but aren't failures by themselves.
+	// They are logged with the Warn level and recorded on the spans as exceptions.
+	SeverityWarning
+	// SeverityCritical errors are the failures to alert on. They are logged with the Error level,
+	// fail the spans and increment the errors counter.
+	SeverityCritical
+)
+
+// SeverityFromHTTP returns the default severity for the HTTP code:
+// critical for 5xx, expected otherwise.
+func SeverityFromHTTP(httpCode int) Severity {
+	if httpCode >= 500 {
+		return SeverityCritical
+	}
+	return SeverityExpected
+}
+
+// SeverityOf returns the severity of the error's Coded; errors without one are critical.
+func SeverityOf(err error) Severity {
+	c := Code(err)
+	if c == nil {
+		return SeverityCritical
+	}
+	return PolicyOf(c).Severity()
+}
+
+// Level returns the log level of the severity.
+func (s Severity) Level() slog.Level {
+	switch s {
+	case SeverityExpected:
+		return slog.LevelDebug
+	case SeverityWarning:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityExpected:
+		return "expected"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return ""
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "expected":
+		*s = SeverityExpected
+	case "warning":
+		*s = SeverityWarning
+	case "critical":
+		*s = SeverityCritical
+	case "":
+		*s = 0
+	default:
+		return Errorf("unknown severity %q", b)
+	}
+	return nil
+}
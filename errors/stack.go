@@ -0,0 +1,155 @@
+package errors
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	pkg "github.com/pkg/errors"
+)
+
+// Frame is a single frame of the error's stack trace.
+type Frame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// StackTrace holds the frames captured in the error chain.
+type StackTrace struct {
+	// Frames is the stack of the innermost error having one (see New, Errorf and WithStack),
+	// the innermost call first.
+	Frames []Frame `json:"frames,omitempty"`
+	// Wraps lists the sites where the outer errors were wrapped (see Wrap), the innermost one first.
+	Wraps []Frame `json:"wraps,omitempty"`
+}
+
+// StackOptions filter the frames of the stack traces.
+type StackOptions struct {
+	// KeepStdlib keeps the frames of the runtime and the standard library.
+	KeepStdlib bool
+	// MaxFrames limits the number of the Frames and Wraps each; zero means no limit.
+	MaxFrames int
+}
+
+var stackOptions atomic.Pointer[StackOptions]
+
+func init() {
+	SetStackOptions(StackOptions{MaxFrames: 32})
+}
+
+// SetStackOptions sets the filtering of the stack traces returned by Stack.
+// caisson sets them from the log config (see plconfig).
+func SetStackOptions(o StackOptions) {
+	stackOptions.Store(&o)
+}
+
+// Stack returns the stack trace captured in the error chain.
+//
+// The chain is followed via Unwrap() error, and into the first branch of errors.Join.
+func Stack(err error) StackTrace {
+	opts := *stackOptions.Load()
+
+	var traces []pkg.StackTrace
+	for err != nil {
+		if st, ok := err.(interface{ StackTrace() pkg.StackTrace }); ok {
+			traces = append(traces, st.StackTrace())
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			errs := u.Unwrap()
+			err = nil
+			if len(errs) > 0 {
+				err = errs[0]
+			}
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	if len(traces) == 0 {
+		return StackTrace{}
+	}
+
+	var ret StackTrace
+	ret.Frames = frames(traces[len(traces)-1], opts)
+	for i := len(traces) - 2; i >= 0; i-- {
+		if opts.MaxFrames > 0 && len(ret.Wraps) >= opts.MaxFrames {
+			break
+		}
+		if wrap := frames(traces[i], StackOptions{KeepStdlib: opts.KeepStdlib, MaxFrames: 1}); len(wrap) > 0 {
+			ret.Wraps = append(ret.Wraps, wrap[0])
+		}
+	}
+	return ret
+}
+
+func frames(st pkg.StackTrace, opts StackOptions) []Frame {
+	pcs := make([]uintptr, len(st))
+	for i, f := range st {
+		pcs[i] = uintptr(f)
+	}
+
+	var ret []Frame
+	it := runtime.CallersFrames(pcs)
+	for {
+		f, more := it.Next()
+		if f.Function != "" && !isOwn(f.File) && (opts.KeepStdlib || !isStdlib(f.Function)) {
+			ret = append(ret, Frame{Func: f.Function, File: f.File, Line: f.Line})
+			if opts.MaxFrames > 0 && len(ret) >= opts.MaxFrames {
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return ret
+}
+
+var ownDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// isOwn reports whether the frame belongs to this package's wrappers of pkg/errors,
+// which capture the stacks on behalf of their callers.
+func isOwn(file string) bool {
+	return filepath.Dir(file) == ownDir && !strings.HasSuffix(file, "_test.go")
+}
+
+// isStdlib reports whether the function belongs to the runtime or the standard library:
+// their import paths' first elements have no dots, like net/http.
+func isStdlib(fn string) bool {
+	path := funcPackage(fn)
+	if path == "main" {
+		return false
+	}
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// String renders the trace like the Go's panics do; it is suitable for the exception.stacktrace
+// attribute of the OpenTelemetry spans.
+func (s StackTrace) String() string {
+	var b strings.Builder
+	write := func(fs []Frame) {
+		for _, f := range fs {
+			b.WriteString(f.Func)
+			b.WriteString("\n\t")
+			b.WriteString(f.File)
+			b.WriteString(":")
+			b.WriteString(strconv.Itoa(f.Line))
+			b.WriteString("\n")
+		}
+	}
+	write(s.Frames)
+	if len(s.Wraps) > 0 {
+		b.WriteString("wrapped at:\n")
+		write(s.Wraps)
+	}
+	return b.String()
+}
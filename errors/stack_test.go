@@ -0,0 +1,51 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func stackOrigin() error {
+	return New("origin")
+}
+
+func stackWrapper() error {
+	return Wrap(stackOrigin(), "wrapped")
+}
+
+func TestStack(t *testing.T) {
+	t.Cleanup(func() { SetStackOptions(StackOptions{MaxFrames: 32}) })
+
+	t.Run("frames and wraps", func(t *testing.T) {
+		so := require.New(t)
+
+		st := Stack(NewCoder("X").Wrap(stackWrapper()))
+		so.NotEmpty(st.Frames)
+		so.Equal("github.com/utrack/caisson-go/errors.stackOrigin", st.Frames[0].Func)
+		so.True(strings.HasSuffix(st.Frames[0].File, "stack_test.go"))
+		for _, f := range st.Frames {
+			so.False(strings.HasPrefix(f.Func, "testing."), f.Func)
+		}
+
+		so.Len(st.Wraps, 1)
+		so.Equal("github.com/utrack/caisson-go/errors.stackWrapper", st.Wraps[0].Func)
+		so.Contains(st.String(), "wrapped at:\ngithub.com/utrack/caisson-go/errors.stackWrapper\n\t")
+	})
+	t.Run("options", func(t *testing.T) {
+		so := require.New(t)
+
+		SetStackOptions(StackOptions{KeepStdlib: true, MaxFrames: 2})
+		st := Stack(stackWrapper())
+		so.Len(st.Frames, 2)
+
+		SetStackOptions(StackOptions{KeepStdlib: true})
+		st = Stack(stackWrapper())
+		so.Equal("testing.tRunner", st.Frames[len(st.Frames)-2].Func)
+	})
+	t.Run("no stack", func(t *testing.T) {
+		so := require.New(t)
+		so.Equal(StackTrace{}, Stack(NewCoder("X")))
+	})
+}
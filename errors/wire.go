@@ -0,0 +1,256 @@
+package errors
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/utrack/caisson-go/levels/level3/errorbag"
+	"google.golang.org/grpc/codes"
+)
+
+// WireError is the wire format of the error chains, used to pass the errors between the processes.
+//
+// Every Coded of the chain starts a node; the nodes of the inner Coded errors are listed as Causes.
+// Only the public keyed data is carried (see WithPublicData), including the CoderDetailers' details,
+// unless the internal details are exposed (see SetExposeInternal).
+type WireError struct {
+	Type     string `json:"type,omitempty"`
+	HTTPCode int    `json:"http_code,omitempty"`
+	// GRPCCode is set if the Coder sets it explicitly.
+	GRPCCode codes.Code `json:"grpc_code,omitempty"`
+	Message  string     `json:"message,omitempty"`
+	// Retryable and RetryAfter are set via Coder.Retryable and Coder.RetryAfter.
+	Retryable  bool          `json:"retryable,omitempty"`
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+	// Severity is set if the Coder sets it explicitly.
+	Severity Severity `json:"severity,omitempty"`
+	// Detail is the error's text, like the wrapped errors' messages;
+	// it is internal, so it's blank unless the internal details are exposed.
+	Detail string                     `json:"detail,omitempty"`
+	Data   map[string]json.RawMessage `json:"data,omitempty"`
+	Cause  *WireError                 `json:"cause,omitempty"`
+}
+
+// Marshal encodes the error chain as the JSON WireError.
+func Marshal(err error) ([]byte, error) {
+	return json.Marshal(ToWire(err))
+}
+
+// Unmarshal decodes the JSON WireError and rebuilds the error; see WireError.Err.
+func Unmarshal(data []byte) (remote error, err error) {
+	var w *WireError
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, Wrap(err, "when decoding the wire error")
+	}
+	return w.Err(), nil
+}
+
+// ToWire converts the error chain to the WireError; nil error returns nil.
+//
+// The branches of errors.Join and other Unwrap() []error errors are not kept as the nodes:
+// their Coded and public data are merged into the current node, see Code for the precedence.
+func ToWire(err error) *WireError {
+	if err == nil {
+		return nil
+	}
+	expose := ExposeInternal()
+	node := func(e error) *WireError {
+		if expose {
+			return &WireError{Detail: e.Error()}
+		}
+		return &WireError{}
+	}
+	root := node(err)
+	cur, seen := root, false
+
+	for e := err; e != nil; {
+		if seen && startsNode(e) {
+			cur.Cause = node(e)
+			cur, seen = cur.Cause, false
+		}
+		if d, ok := e.(Detailed[Coded]); ok {
+			cur.setCoded(d.Value())
+			seen = true
+		} else if key, value, public, ok := errorbag.Pair(e); ok && (public || expose) {
+			cur.addData(key, value)
+		}
+
+		switch u := e.(type) {
+		case interface{ Unwrap() []error }:
+			if !seen {
+				if c := Code(e); c != nil {
+					cur.setCoded(c)
+				}
+			}
+			pairs := errorbag.ListPublicPairs(e)
+			if expose {
+				pairs = errorbag.ListPairs(e)
+			}
+			keys := make([]string, 0, len(pairs))
+			for k := range pairs {
+				keys = append(keys, k)
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				cur.addData(k, pairs[k])
+			}
+			e = nil
+		case interface{ Unwrap() error }:
+			e = u.Unwrap()
+		default:
+			e = nil
+		}
+	}
+	return root
+}
+
+// startsNode reports whether the error is the Coded or the CoderDetailer's details wrapping it.
+func startsNode(err error) bool {
+	if _, ok := err.(Detailed[Coded]); ok {
+		return true
+	}
+	if _, _, _, ok := errorbag.Pair(err); !ok {
+		return false
+	}
+	_, ok := Unwrap(err).(Detailed[Coded])
+	return ok
+}
+
+func (w *WireError) setCoded(c Coded) {
+	w.Type = c.Type()
+	w.HTTPCode = c.HTTPCode()
+	w.Message = c.Message()
+	p := PolicyOf(c)
+	w.Retryable = p.Retryable()
+	w.RetryAfter = p.RetryAfter()
+	if cd, ok := c.(coded); ok {
+		w.GRPCCode = cd.GrpcCode
+		w.Severity = cd.Sev
+	} else {
+		w.GRPCCode = p.GRPCCode()
+		w.Severity = p.Severity()
+	}
+}
+
+// addData adds the pair unless the key is taken by an outer one.
+func (w *WireError) addData(key string, value any) {
+	if key == coderType.String() {
+		// carried by the node's fields
+		return
+	}
+	if _, ok := w.Data[key]; ok {
+		return
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if w.Data == nil {
+		w.Data = map[string]json.RawMessage{}
+	}
+	w.Data[key] = raw
+}
+
+// Err rebuilds the error: errors.Code returns its Coded, the data is available via KeyedData
+// as strings (JSON-encoded unless it's a string), and errors.Is matches the Coders registered
+// in the catalogue (see Register); the registered CoderDetailers' details are decoded as well.
+// The error's text is the Detail, or the Message if the Detail is blank.
+//
+// Nil WireError returns a nil error.
+func (w *WireError) Err() error {
+	if w == nil {
+		return nil
+	}
+	text := w.Detail
+	if text == "" {
+		text = w.Message
+	}
+	var err error = &wireError{text: text, cause: w.Cause.Err()}
+
+	c := w.coder()
+	detailKey := ""
+	if spec, ok := SpecOf(c); ok && spec.DetailType != nil {
+		detailKey = spec.DetailType.String()
+	}
+
+	keys := make([]string, 0, len(w.Data))
+	for k := range w.Data {
+		if k != detailKey {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		var s string
+		if json.Unmarshal(w.Data[k], &s) != nil {
+			s = string(w.Data[k])
+		}
+		err = WithPublicData(err, k, s)
+	}
+
+	if c == nil {
+		return err
+	}
+	return c.(wireWrapper).wrapWire(err, w.Data[detailKey])
+}
+
+// coder returns the registered Coder of the node, or a new one if there's none.
+func (w *WireError) coder() error {
+	if w.Type == "" && w.HTTPCode == 0 {
+		return nil
+	}
+	if c, ok := registered(w.Type, w.HTTPCode); ok {
+		return c
+	}
+	c := NewCoder(w.Type).WithHTTPCode(w.HTTPCode).WithMessage(w.Message)
+	if w.GRPCCode != codes.OK {
+		c = c.WithGRPCCode(w.GRPCCode)
+	}
+	if w.Severity != 0 {
+		c = c.WithSeverity(w.Severity)
+	}
+	if w.RetryAfter > 0 {
+		c = c.RetryAfter(w.RetryAfter)
+	} else if w.Retryable {
+		c = c.Retryable()
+	}
+	return c
+}
+
+// wireWrapper is implemented by the Coders to rebuild the errors unmarshaled from the WireError.
+type wireWrapper interface {
+	// wrapWire wraps the cause; details are the raw JSON of the CoderDetailer's details, if any.
+	wrapWire(cause error, details json.RawMessage) error
+}
+
+var (
+	_ wireWrapper = coder{}
+	_ wireWrapper = coderDetailer[string]{}
+)
+
+func (c coder) wrapWire(cause error, _ json.RawMessage) error {
+	return c.Wrap(cause)
+}
+
+func (c coderDetailer[T]) wrapWire(cause error, details json.RawMessage) error {
+	var v T
+	if details == nil || json.Unmarshal(details, &v) != nil {
+		return c.coder.Wrap(cause)
+	}
+	return c.Wrap(cause, v)
+}
+
+// wireError is the text of the unmarshaled error.
+type wireError struct {
+	text  string
+	cause error
+}
+
+func (e *wireError) Error() string {
+	return e.text
+}
+
+func (e *wireError) Unwrap() error {
+	return e.cause
+}
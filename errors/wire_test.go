@@ -0,0 +1,92 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+type wireDetail struct {
+	ItemID string `json:"item_id"`
+}
+
+var (
+	errWireNotFound = Register(NewCoder("TEST_WIRE_NOT_FOUND").WithHTTPCode(404).WithMessage("not found"))
+	errWireLocked   = Register(NewCoderDetailer[wireDetail]("TEST_WIRE_LOCKED").WithHTTPCode(409).WithGRPCCode(codes.Aborted))
+)
+
+func TestMarshal(t *testing.T) {
+	t.Run("chain", func(t *testing.T) {
+		so := require.New(t)
+
+		inner := errWireLocked.Wrap(New("row locked"), wireDetail{ItemID: "a1"})
+		err := WithPublicData(Wrapd(errWireNotFound.Wrap(Wrap(inner, "get item")), "handler", "query", "SELECT 1"), "attempt", 2)
+
+		buf, merr := Marshal(err)
+		so.NoError(merr)
+		so.JSONEq(`{
+			"type": "TEST_WIRE_NOT_FOUND", "http_code": 404, "message": "not found",
+			"data": {"attempt": 2},
+			"cause": {
+				"type": "TEST_WIRE_LOCKED", "http_code": 409, "grpc_code": 10,
+				"data": {"errors.wireDetail": {"item_id": "a1"}}
+			}
+		}`, string(buf))
+
+		got, uerr := Unmarshal(buf)
+		so.NoError(uerr)
+		so.Equal("not found", got.Error())
+		so.True(Is(got, errWireNotFound))
+		so.Equal("TEST_WIRE_NOT_FOUND", Code(got).Type())
+
+		attempt, ok := KeyedData[string, string](got, "attempt")
+		so.True(ok)
+		so.Equal("2", attempt)
+		_, ok = KeyedData[string, string](got, "query")
+		so.False(ok)
+
+		so.Equal(&wireDetail{ItemID: "a1"}, errWireLocked.ExtractDetail(got))
+		so.Equal(codes.Aborted, PolicyOf(Code(Unwrap(Unwrap(Unwrap(got))))).GRPCCode())
+	})
+	t.Run("exposed", func(t *testing.T) {
+		so := require.New(t)
+		SetExposeInternal(true)
+		t.Cleanup(func() { SetExposeInternal(false) })
+
+		err := Wrapd(errWireNotFound.Wrap(New("no rows")), "handler", "query", "SELECT 1")
+		w := ToWire(err)
+		so.Equal("handler: no rows", w.Detail)
+		so.JSONEq(`"SELECT 1"`, string(w.Data["query"]))
+
+		got := w.Err()
+		so.Equal("handler: no rows", got.Error())
+		query, ok := KeyedData[string, string](got, "query")
+		so.True(ok)
+		so.Equal("SELECT 1", query)
+	})
+	t.Run("unregistered", func(t *testing.T) {
+		so := require.New(t)
+
+		c := NewCoder("TEST_WIRE_UNKNOWN").WithHTTPCode(418).WithMessage("teapot").WithGRPCCode(codes.Unimplemented)
+		buf, err := Marshal(Join(New("a"), c.Wrap(New("b"))))
+		so.NoError(err)
+
+		got, err := Unmarshal(buf)
+		so.NoError(err)
+		so.True(Is(got, c))
+		so.Equal("teapot", got.Error())
+	})
+	t.Run("nil", func(t *testing.T) {
+		so := require.New(t)
+
+		buf, err := Marshal(nil)
+		so.NoError(err)
+		got, err := Unmarshal(buf)
+		so.NoError(err)
+		so.NoError(got)
+
+		_, err = Unmarshal([]byte("{"))
+		so.Error(err)
+	})
+}
@@ -7,24 +7,49 @@ import (
 
 	"github.com/go-logr/logr"
 	"github.com/utrack/caisson-go/closer"
+	"github.com/utrack/caisson-go/errors"
 	"github.com/utrack/caisson-go/levels/level3/l3closer"
+	"github.com/utrack/caisson-go/levels/level3/logctx"
 	"github.com/utrack/caisson-go/log"
 	"github.com/utrack/caisson-go/pkg/plconfig"
+	"github.com/utrack/caisson-go/pkg/slogdedup"
 	"github.com/utrack/caisson-go/pkg/slogtrace"
 	"go.opentelemetry.io/otel"
 )
 
 func Ensure() {
 
+	cfg := plconfig.Get()
+
 	// slogtrace extracts trace_id/span_id from the context. Use it for the global logger.
-	handler := slogtrace.NewContextHandler(
+	var handler slog.Handler = slogtrace.NewContextHandler(
 		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			Level: slog.LevelDebug,
 		}))
 
+	var dedup *slogdedup.Handler
+	if cfg.Log.Dedup.Enable {
+		dedup = slogdedup.NewHandler(handler, slogdedup.Options{
+			Interval: cfg.Log.Dedup.Interval,
+			Burst:    cfg.Log.Dedup.Burst,
+		})
+		handler = dedup
+	}
+
+	// the per-request level overrides (see logctx.WithLevel) are applied on top of the configured level
+	handler = logctx.NewLevelHandler(handler, cfg.Log.Level)
+
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
+	errors.SetStackOptions(errors.StackOptions{
+		KeepStdlib: cfg.Log.Stack.KeepStdlib,
+		MaxFrames:  cfg.Log.Stack.MaxFrames,
+	})
+
+	// the internal error details go to the API clients in the development mode only
+	errors.SetExposeInternal(cfg.Mode == plconfig.ModeDevelopment)
+
 	olog := logr.FromSlogHandler(handler)
 	otel.SetLogger(olog)
 	closeTracer := initTracer()
@@ -32,8 +57,12 @@ func Ensure() {
 
 	closer.RegisterFuncC(closeTracer)
 	closer.RegisterFuncC(closeMetrics)
+	if dedup != nil {
+		// closed before the exporters, so that the final summaries are flushed
+		closer.RegisterC(dedup)
+	}
 
-	slog.Info("caisson-go environment initialized", "config", plconfig.Get())
+	slog.Info("caisson-go environment initialized", "config", cfg)
 }
 
 // Stop gracefully stops the environment, including anything registered via [github.com/utrack/caisson-go/closer].Register*.
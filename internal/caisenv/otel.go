@@ -6,6 +6,7 @@ import (
 	"log/slog"
 
 	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/observe/tracer"
 	"github.com/utrack/caisson-go/pkg/plconfig"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -69,7 +70,7 @@ func initTracer() func(context.Context) error {
 
 	otel.SetTracerProvider(
 		sdktrace.NewTracerProvider(
-			sdktrace.WithSampler(sdktrace.AlwaysSample()),
+			sdktrace.WithSampler(tracer.NewSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Otel.SampleRatio)))),
 			sdktrace.WithBatcher(exporter),
 			sdktrace.WithResource(resources),
 		),
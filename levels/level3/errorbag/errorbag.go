@@ -11,7 +11,6 @@
 package errorbag
 
 import (
-	"errors"
 	"fmt"
 )
 
@@ -28,6 +27,7 @@ type Bag[K comparable, T comparable] interface {
 type bagAny interface {
 	keyAny() any
 	valueAny() any
+	isPublic() bool
 }
 
 // With creates a new bag with the given cause, key, and value.
@@ -47,67 +47,128 @@ func With[K comparable, T comparable](cause error, key K, value T) Bag[K, T] {
 	}
 }
 
-// Get returns the value associated with the given key; recursing into the error chain to find it.
+// WithPublic is an equivalent of With, but the pair is marked as public:
+// safe to be shown to the API clients. See ListPublicPairs.
+func WithPublic[K comparable, T comparable](cause error, key K, value T) Bag[K, T] {
+	if cause == nil {
+		return nil
+	}
+	return container[K, T]{
+		cause:  cause,
+		key:    key,
+		value:  value,
+		public: true,
+	}
+}
+
+// Get returns the value associated with the given key; recursing into the error tree to find it.
 //
-// It follows the errors.Unwrap() semantics; key-value pairs behind the errors.Join are unsupported.
+// The tree is traversed depth-first, following both the errors.Unwrap() chains and
+// the errors.Join-like Unwrap() []error branches in order; the first value found wins.
 func Get[K comparable, T comparable](err error, key K) (T, bool) {
-	for err != nil {
-		if bag, ok := err.(Bag[K, T]); ok {
-			if bag.Key() == key {
-				return bag.Value(), true
-			}
+	var ret T
+	var found bool
+	walk(err, func(err error) bool {
+		if bag, ok := err.(Bag[K, T]); ok && bag.Key() == key {
+			ret, found = bag.Value(), true
+			return false
 		}
-		err = errors.Unwrap(err)
-	}
-	return *new(T), false
+		return true
+	})
+	return ret, found
 }
 
-// GetAll returns all the values associated with the given key; recursing into the error chain to find them.
+// GetAll returns all the values associated with the given key; recursing into the error tree to find them.
 //
-// It follows the errors.Unwrap() semantics; key-value pairs behind the errors.Join are unsupported.
+// The values are listed in the depth-first traversal order; see Get.
 func GetAll[K comparable, T comparable](err error, key K) ([]T, bool) {
 	var ret []T
+	walk(err, func(err error) bool {
+		if bag, ok := err.(Bag[K, T]); ok && bag.Key() == key {
+			ret = append(ret, bag.Value())
+		}
+		return true
+	})
+	return ret, len(ret) > 0
+}
+
+// walk visits the error tree depth-first: the error itself, then its causes returned by
+// Unwrap() error, or by Unwrap() []error in order. It stops once fn returns false.
+func walk(err error, fn func(error) bool) bool {
 	for err != nil {
-		if bag, ok := err.(Bag[K, T]); ok {
-			if bag.Key() == key {
-				ret = append(ret, bag.Value())
+		if !fn(err) {
+			return false
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				if !walk(e, fn) {
+					return false
+				}
 			}
+			return true
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		default:
+			return true
 		}
-		err = errors.Unwrap(err)
 	}
-	return ret, len(ret) > 0
+	return true
+}
+
+// Pair returns the key-value pair held by the error itself, without unwrapping it.
+// ok is false if the error isn't a bag.
+func Pair(err error) (key string, value any, public bool, ok bool) {
+	bag, ok := err.(bagAny)
+	if !ok {
+		return "", nil, false, false
+	}
+	return fmt.Sprintf("%s", bag.keyAny()), bag.valueAny(), bag.isPublic(), true
 }
 
-// ListPairs returns all the key-value pairs associated with the given error; recursing into the error chain to find them.
+// ListPairs returns all the key-value pairs associated with the given error; recursing into the error tree to find them.
+// The values of the duplicate keys are listed in the depth-first traversal order; see Get.
 //
 // Listing the pairs is a potentially expensive operation, as it requires
-// traversing the entire error chain.
+// traversing the entire error tree.
 func ListPairs(err error) map[string]any {
+	return listPairs(err, false)
+}
+
+// ListPublicPairs is an equivalent of ListPairs, but lists only the pairs added via WithPublic.
+func ListPublicPairs(err error) map[string]any {
+	return listPairs(err, true)
+}
+
+func listPairs(err error, publicOnly bool) map[string]any {
 	ret := make(map[string]any, 3)
-	for err != nil {
-		if bag, ok := err.(bagAny); ok {
-			keyStr := fmt.Sprintf("%s", bag.keyAny())
-			if v, ok := ret[keyStr]; ok {
-				if asArray, ok := v.([]any); ok {
-					asArray = append(asArray, bag.valueAny())
-					ret[keyStr] = asArray
-				} else {
-					ret[keyStr] = []any{v, bag.valueAny()}
-				}
+	walk(err, func(err error) bool {
+		bag, ok := err.(bagAny)
+		if !ok || (publicOnly && !bag.isPublic()) {
+			return true
+		}
+		keyStr := fmt.Sprintf("%s", bag.keyAny())
+		if v, ok := ret[keyStr]; ok {
+			if asArray, ok := v.([]any); ok {
+				asArray = append(asArray, bag.valueAny())
+				ret[keyStr] = asArray
 			} else {
-				ret[keyStr] = bag.valueAny()
+				ret[keyStr] = []any{v, bag.valueAny()}
 			}
+		} else {
+			ret[keyStr] = bag.valueAny()
 		}
-		err = errors.Unwrap(err)
-	}
+		return true
+	})
 	return ret
 }
 
 type container[K comparable, T comparable] struct {
 	cause error
 
-	key   K
-	value T
+	key    K
+	value  T
+	public bool
 }
 
 func (c container[K, T]) Error() string {
@@ -134,6 +195,10 @@ func (c container[K, T]) valueAny() any {
 	return c.value
 }
 
+func (c container[K, T]) isPublic() bool {
+	return c.public
+}
+
 func (c container[K, T]) Is(target error) bool {
 	if v, ok := target.(Bag[K, T]); ok {
 		return v.Key() == c.key
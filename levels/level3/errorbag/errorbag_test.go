@@ -0,0 +1,34 @@
+package errorbag
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinTraversal(t *testing.T) {
+	so := require.New(t)
+
+	left := With(With(errors.New("a"), "item", "a1"), "row", 1)
+	right := fmt.Errorf("batch: %w", With(errors.New("b"), "item", "b2"))
+	err := With(errors.Join(left, right), "batch", "42")
+
+	v, ok := Get[string, string](err, "item")
+	so.True(ok)
+	so.Equal("a1", v)
+
+	all, ok := GetAll[string, string](err, "item")
+	so.True(ok)
+	so.Equal([]string{"a1", "b2"}, all)
+
+	so.Equal(map[string]any{
+		"batch": "42",
+		"item":  []any{"a1", "b2"},
+		"row":   1,
+	}, ListPairs(err))
+
+	_, ok = Get[string, int](err, "missing")
+	so.False(ok)
+}
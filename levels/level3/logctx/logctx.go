@@ -17,6 +17,14 @@ func With(ctx context.Context, kvs ...any) context.Context {
 	return context.WithValue(ctx, ctxLogKey, newLogger)
 }
 
+// WithLogger returns a new context carrying the given logger.
+// Any calls to the logger from the new context will use it instead of the parent's one.
+//
+// Use it to redirect the logs of a single call tree (e.g. in tests) without touching the [slog.Default()] logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxLogKey, logger)
+}
+
 // From returns the logger from the context.
 // If the context does not have a logger, returns the default [slog.Default()] logger.
 func From(ctx context.Context) *slog.Logger {
@@ -26,3 +34,63 @@ func From(ctx context.Context) *slog.Logger {
 	}
 	return ret
 }
+
+type ctxLevelKeyType string
+
+const ctxLevelKey ctxLevelKeyType = "caisson.l3.logctx.level"
+
+// WithLevel returns a new context which overrides the minimum log level for any logs emitted with it.
+//
+// The override is respected only by the loggers using the [NewLevelHandler] handler;
+// use it to turn on the debug logs for a single request.
+func WithLevel(ctx context.Context, level slog.Leveler) context.Context {
+	return context.WithValue(ctx, ctxLevelKey, level)
+}
+
+// LevelFrom returns the minimum log level set for the context via [WithLevel].
+func LevelFrom(ctx context.Context) (slog.Leveler, bool) {
+	ret, ok := ctx.Value(ctxLevelKey).(slog.Leveler)
+	return ret, ok
+}
+
+// NewLevelHandler returns an [slog.Handler] which drops the records below the base level,
+// unless the context's level was overridden via [WithLevel].
+//
+// The inner handler should accept the records of any level.
+func NewLevelHandler(inner slog.Handler, base slog.Leveler) slog.Handler {
+	return &levelHandler{inner: inner, base: base}
+}
+
+type levelHandler struct {
+	inner slog.Handler
+	base  slog.Leveler
+}
+
+// Enabled implements [slog.Handler].
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	minLevel := h.base
+	if ctx != nil {
+		if override, ok := LevelFrom(ctx); ok {
+			minLevel = override
+		}
+	}
+	if level < minLevel.Level() {
+		return false
+	}
+	return h.inner.Enabled(ctx, level)
+}
+
+// Handle implements [slog.Handler].
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements [slog.Handler].
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{inner: h.inner.WithAttrs(attrs), base: h.base}
+}
+
+// WithGroup implements [slog.Handler].
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{inner: h.inner.WithGroup(name), base: h.base}
+}
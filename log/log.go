@@ -2,7 +2,6 @@ package log
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"os"
 
@@ -39,11 +38,17 @@ func Warne(ctx context.Context, msg string, err error, kvs ...any) {
 
 func errKvs(err error, kvs []any) []any {
 	kvs = append(kvs, "error.message", err.Error())
-	kvs = append(kvs, "error.stack", fmt.Sprintf("%+v", err))
+	if st := errors.Stack(err); len(st.Frames) > 0 || len(st.Wraps) > 0 {
+		kvs = append(kvs, "error.stack", st.Frames)
+		if len(st.Wraps) > 0 {
+			kvs = append(kvs, "error.wraps", st.Wraps)
+		}
+	}
 	if code := errors.Code(err); code != nil {
 		kvs = append(kvs, "error.code", code.Type())
 		kvs = append(kvs, "error.user_message", code.Message())
 	}
+	kvs = append(kvs, "error.severity", errors.SeverityOf(err).String())
 
 	data := errorbag.ListPairs(err)
 	if len(data) > 0 {
@@ -58,6 +63,14 @@ func Error(ctx context.Context, msg string, err error, kvs ...any) {
 	}
 }
 
+// Errorv logs the error with the level of its severity (see errors.Severity):
+// the expected errors are logged with the Debug level, the warnings with Warn and the critical ones with Error.
+func Errorv(ctx context.Context, msg string, err error, kvs ...any) {
+	if err != nil {
+		logctx.From(ctx).Log(ctx, errors.SeverityOf(err).Level(), msg, errKvs(err, kvs)...)
+	}
+}
+
 // Errorn emits a log with level Error but it does not add any error context.
 // In 99% of the cases you want to use Error or Errorne instead.
 func Errorn(ctx context.Context, msg string, kvs ...any) {
@@ -0,0 +1,269 @@
+/*
+Package logtest captures the logs emitted via [github.com/utrack/caisson-go/log] for assertions in tests.
+
+It does not swap the [slog.Default()] logger; instead, it returns a context carrying its own logger,
+so that parallel tests don't interfere with each other:
+
+	func TestSomething(t *testing.T) {
+		t.Parallel()
+		ctx, logs := logtest.New(t)
+
+		err := DoSomething(ctx)
+
+		logs.AssertLogged(t, slog.LevelError, "failed to do something",
+			"error.code", "NOT_FOUND",
+			"error.data.user_id", 31337,
+		)
+	}
+*/
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/utrack/caisson-go/levels/level3/logctx"
+)
+
+// Record is a single captured log record.
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+
+	// Attrs are the record's attributes, including the ones added via log.With().
+	// Grouped attributes are flattened; their keys are joined with a dot.
+	Attrs map[string]slog.Value
+}
+
+// Value returns the attribute stored under the key.
+//
+// Keys under the map-valued attributes (like `error.data` emitted by log.Error)
+// can be addressed with a dot as well, e.g. `error.data.user_id`.
+func (r Record) Value(key string) (slog.Value, bool) {
+	if v, ok := r.Attrs[key]; ok {
+		return v, true
+	}
+	for k, v := range r.Attrs {
+		sub, ok := strings.CutPrefix(key, k+".")
+		if !ok || v.Kind() != slog.KindAny {
+			continue
+		}
+		if m, ok := v.Any().(map[string]any); ok {
+			if got, ok := m[sub]; ok {
+				return slog.AnyValue(got), true
+			}
+		}
+	}
+	return slog.Value{}, false
+}
+
+func (r Record) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v %q", r.Level, r.Message)
+	for k, v := range r.Attrs {
+		fmt.Fprintf(&b, " %v=%v", k, v)
+	}
+	return b.String()
+}
+
+// Logs is an inspectable buffer of the captured log records.
+// It is safe for concurrent use.
+type Logs struct {
+	m       sync.Mutex
+	records []Record
+}
+
+// New returns a context whose logger writes to the returned Logs.
+//
+// The captured records are dumped to the test's output if the test fails.
+func New(t testing.TB) (context.Context, *Logs) {
+	t.Helper()
+	logs := &Logs{}
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		for _, r := range logs.Records() {
+			t.Log("captured log:", r.String())
+		}
+	})
+	return logs.Context(t.Context()), logs
+}
+
+// Context returns a child context whose logger writes to the Logs.
+func (l *Logs) Context(ctx context.Context) context.Context {
+	return logctx.WithLogger(ctx, slog.New(&handler{logs: l}))
+}
+
+// Records returns a copy of all the records captured so far.
+func (l *Logs) Records() []Record {
+	l.m.Lock()
+	defer l.m.Unlock()
+	ret := make([]Record, len(l.records))
+	copy(ret, l.records)
+	return ret
+}
+
+// Reset drops all the captured records.
+func (l *Logs) Reset() {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.records = nil
+}
+
+// Find returns all the records of the given level which contain msgSubstring in their message
+// and match every key-value pair in kvs.
+//
+// See [Record.Value] for the key syntax. Values are compared the way slog stores them,
+// so an int matches an int64 of the same value.
+func (l *Logs) Find(level slog.Level, msgSubstring string, kvs ...any) []Record {
+	var ret []Record
+	for _, r := range l.Records() {
+		if r.Level != level || !strings.Contains(r.Message, msgSubstring) {
+			continue
+		}
+		if matchKvs(r, kvs) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// AssertLogged fails the test if no record matches the level, message substring and key-value pairs.
+// Returns the first matching record.
+//
+// Use the `error.message`, `error.code`, `error.user_message` and `error.data.<key>` keys
+// to check the errors logged via log.Error().
+func (l *Logs) AssertLogged(t testing.TB, level slog.Level, msgSubstring string, kvs ...any) Record {
+	t.Helper()
+	found := l.Find(level, msgSubstring, kvs...)
+	if len(found) == 0 {
+		t.Errorf("logtest: no %v record containing %q with %v was logged; got %d record(s):\n%v",
+			level, msgSubstring, kvs, len(l.Records()), l.dump())
+		return Record{}
+	}
+	return found[0]
+}
+
+// AssertNotLogged fails the test if any record matches the level, message substring and key-value pairs.
+func (l *Logs) AssertNotLogged(t testing.TB, level slog.Level, msgSubstring string, kvs ...any) {
+	t.Helper()
+	found := l.Find(level, msgSubstring, kvs...)
+	if len(found) > 0 {
+		t.Errorf("logtest: expected no %v record containing %q with %v, got:\n%v",
+			level, msgSubstring, kvs, found[0].String())
+	}
+}
+
+func (l *Logs) dump() string {
+	var b strings.Builder
+	for _, r := range l.Records() {
+		b.WriteString("\t")
+		b.WriteString(r.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (l *Logs) add(r Record) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.records = append(l.records, r)
+}
+
+func matchKvs(r Record, kvs []any) bool {
+	for i := 0; i+1 < len(kvs); i += 2 {
+		got, ok := r.Value(fmt.Sprintf("%v", kvs[i]))
+		if !ok {
+			return false
+		}
+		if !got.Resolve().Equal(slog.AnyValue(kvs[i+1]).Resolve()) {
+			return false
+		}
+	}
+	return true
+}
+
+var _ slog.Handler = &handler{}
+
+// handler is an [slog.Handler] that writes records to the Logs.
+type handler struct {
+	logs *Logs
+
+	// attrs are the flattened attributes added via WithAttrs.
+	attrs map[string]slog.Value
+	// prefix is the current group prefix, joined with dots.
+	prefix string
+}
+
+func (h *handler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]slog.Value, len(h.attrs)+r.NumAttrs())
+	for k, v := range h.attrs {
+		attrs[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		flatten(attrs, h.prefix, a)
+		return true
+	})
+	h.logs.add(Record{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   attrs,
+	})
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	ret := &handler{
+		logs:   h.logs,
+		attrs:  make(map[string]slog.Value, len(h.attrs)+len(attrs)),
+		prefix: h.prefix,
+	}
+	for k, v := range h.attrs {
+		ret.attrs[k] = v
+	}
+	for _, a := range attrs {
+		flatten(ret.attrs, h.prefix, a)
+	}
+	return ret
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{
+		logs:   h.logs,
+		attrs:  h.attrs,
+		prefix: h.prefix + name + ".",
+	}
+}
+
+func flatten(dst map[string]slog.Value, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			flatten(dst, groupPrefix, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	dst[prefix+a.Key] = v
+}
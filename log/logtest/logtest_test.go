@@ -0,0 +1,48 @@
+package logtest_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/log/logtest"
+)
+
+func TestAssertLogged_errorFields(t *testing.T) {
+	t.Parallel()
+	so := require.New(t)
+	ctx, logs := logtest.New(t)
+
+	ErrNotFound := errors.NewCoder("NOT_FOUND").WithHTTPCode(404).WithMessage("not found")
+	err := errors.Wrapd(ErrNotFound.Wrap(errors.New("sql: no rows")), "when fetching user", "user_id", 31337)
+
+	ctx = log.With(ctx, "module", "users")
+	log.Error(ctx, "failed to fetch a user", err)
+
+	rec := logs.AssertLogged(t, slog.LevelError, "failed to fetch",
+		"module", "users",
+		"error.message", "when fetching user: sql: no rows",
+		"error.code", "NOT_FOUND",
+		"error.data.user_id", 31337,
+	)
+	so.Equal("failed to fetch a user", rec.Message)
+
+	so.Empty(logs.Find(slog.LevelError, "failed to fetch", "error.code", "CONFLICT"))
+	logs.AssertNotLogged(t, slog.LevelInfo, "failed to fetch")
+}
+
+func TestLogs_groupsAreFlattened(t *testing.T) {
+	t.Parallel()
+	so := require.New(t)
+	ctx, logs := logtest.New(t)
+
+	log.Info(ctx, "request served", slog.Group("http", "status", 200))
+
+	so.Len(logs.Records(), 1)
+	logs.AssertLogged(t, slog.LevelInfo, "request served", "http.status", 200)
+
+	logs.Reset()
+	so.Empty(logs.Records())
+}
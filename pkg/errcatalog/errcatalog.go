@@ -0,0 +1,85 @@
+/*
+Package errcatalog renders the catalogue of the registered Coders (see errors.Register)
+as JSON, an HTML page and a markdown error reference.
+
+caiapp serves the catalogue on its debug port at /debug/errors (HTML) and /debug/errors.json;
+the cmd/caisson-errdoc command converts the latter to the markdown reference.
+*/
+package errcatalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/utrack/caisson-go/errors"
+)
+
+// Handler serves the current catalogue as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(errors.Catalogue())
+	})
+}
+
+// HandlerHTML serves the current catalogue as the HTML page.
+func HandlerHTML() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := tplCatalogue.Execute(w, errors.Catalogue()); err != nil {
+			_, _ = w.Write([]byte(err.Error()))
+		}
+	})
+}
+
+// Markdown writes the error reference: a table of the error types, their HTTP codes and messages.
+func Markdown(w io.Writer, entries []errors.CatalogueEntry) error {
+	var b strings.Builder
+	b.WriteString("# Error reference\n\n")
+	b.WriteString("Errors are returned as RFC 7807 problem documents; `type` identifies the error.\n")
+	b.WriteString("The details of the errors having them are listed in the problem's `extensions` under the details' key.\n\n")
+	b.WriteString("| Type | HTTP code | Message | Details key |\n")
+	b.WriteString("|------|-----------|---------|-------------|\n")
+	for _, e := range entries {
+		details := ""
+		if e.DetailType != "" {
+			details = "`" + e.DetailType + "`"
+		}
+		fmt.Fprintf(&b, "| `%v` | %d %v | %v | %v |\n",
+			e.Type, e.HTTPCode, http.StatusText(e.HTTPCode), escapeCell(e.Message), details)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeCell keeps the text within the markdown table's cell.
+func escapeCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+var tplCatalogue = template.Must(template.New("catalogue").Parse(`<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8">
+    <title>Error catalogue</title>
+    <link rel="stylesheet" href="https://unpkg.com/chota@0.8.1/dist/chota.min.css">
+  </head>
+  <body>
+  <div class="container">
+    <h1>Error catalogue</h1>
+    <p>Registered via errors.Register; also available as <a href="errors.json">JSON</a>.</p>
+    <table>
+      <tr><th>Type</th><th>HTTP code</th><th>Message</th><th>Details type</th><th>Package</th></tr>
+      {{- range . }}
+      <tr><td><code>{{ .Type }}</code></td><td>{{ .HTTPCode }}</td><td>{{ .Message }}</td><td><code>{{ .DetailType }}</code></td><td><code>{{ .Package }}</code></td></tr>
+      {{- end }}
+    </table>
+  </div>
+  </body>
+</html>
+`))
@@ -0,0 +1,48 @@
+package errcatalog
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+)
+
+func TestMarkdown(t *testing.T) {
+	so := require.New(t)
+
+	var b strings.Builder
+	so.NoError(Markdown(&b, []errors.CatalogueEntry{
+		{Type: "CONFLICT", HTTPCode: 409, Message: "a|b\nc", DetailType: "users.ConflictDetail"},
+		{Type: "NOT_FOUND", HTTPCode: 404, Message: "not found"},
+	}))
+
+	lines := strings.Split(b.String(), "\n")
+	so.Contains(lines, "| `CONFLICT` | 409 Conflict | a\\|b c | `users.ConflictDetail` |")
+	so.Contains(lines, "| `NOT_FOUND` | 404 Not Found | not found |  |")
+}
+
+type handlerDetail struct {
+	Field string
+}
+
+func TestHandler(t *testing.T) {
+	so := require.New(t)
+	errors.Register(errors.NewCoderDetailer[handlerDetail]("TEST_ERRCATALOG_INVALID").WithHTTPCode(422).WithMessage("invalid"))
+
+	rsp := httptest.NewRecorder()
+	Handler().ServeHTTP(rsp, httptest.NewRequest("GET", "/debug/errors.json", nil))
+	so.Equal("application/json", rsp.Header().Get("Content-Type"))
+
+	var entries []errors.CatalogueEntry
+	so.NoError(json.NewDecoder(rsp.Body).Decode(&entries))
+	so.Contains(entries, errors.CatalogueEntry{
+		Type:       "TEST_ERRCATALOG_INVALID",
+		HTTPCode:   422,
+		Message:    "invalid",
+		DetailType: "errcatalog.handlerDetail",
+		Package:    "github.com/utrack/caisson-go/pkg/errcatalog",
+	})
+}
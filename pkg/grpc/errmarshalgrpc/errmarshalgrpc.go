@@ -0,0 +1,165 @@
+// Package errmarshalgrpc converts the errors to gRPC statuses and back.
+//
+// The Coded errors are sent with the errdetails.ErrorInfo detail: its reason is the error's type,
+// the metadata lists the error's key-value pairs (see errorbag.ListPairs) for the foreign clients
+// and the whole error chain as the JSON errors.WireError under the WireKey.
+//
+// Unless errors.SetExposeInternal is on, the statuses carry the public pairs only,
+// and the uncoded errors' messages are replaced with a generic one.
+package errmarshalgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/levels/level3/errorbag"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// WireKey is the ErrorInfo metadata's key of the errors.WireError JSON.
+const WireKey = "caisson.error"
+
+// codedKey is the Coded pair's key in errorbag.ListPairs.
+var codedKey = reflect.TypeFor[errors.Coded]().String()
+
+// ToStatus converts the error chain to the gRPC status.
+// The errors without Coded keep their gRPC status if there's one, or become Internal.
+//
+// Nil error returns a nil status.
+func ToStatus(ctx context.Context, err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	expose := errors.ExposeInternal()
+
+	code := errors.Code(err)
+	if code == nil {
+		if st, ok := status.FromError(err); ok {
+			return st
+		}
+		if expose {
+			return status.New(codes.Internal, err.Error())
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			return status.Newf(codes.Internal, "internal error, trace ID %v", sc.TraceID())
+		}
+		return status.New(codes.Internal, "internal error")
+	}
+
+	msg, pairs := err.Error(), errorbag.ListPairs(err)
+	if !expose {
+		msg, pairs = code.Message(), errorbag.ListPublicPairs(err)
+	}
+	delete(pairs, codedKey)
+	md := metadata(pairs)
+	if buf, merr := errors.Marshal(err); merr == nil {
+		md[WireKey] = string(buf)
+	}
+
+	policy := errors.PolicyOf(code)
+	st := status.New(policy.GRPCCode(), msg)
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   code.Type(),
+		Metadata: md,
+	}}
+	if d := policy.RetryAfter(); d > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(d)})
+	}
+	if ret, err := st.WithDetails(details...); err == nil {
+		return ret
+	}
+	return st
+}
+
+func retryInfo(st *status.Status) *errdetails.RetryInfo {
+	for _, d := range st.Details() {
+		if ri, ok := d.(*errdetails.RetryInfo); ok {
+			return ri
+		}
+	}
+	return nil
+}
+
+// metadata renders the pairs' values as strings; non-string values are JSON-encoded.
+func metadata(pairs map[string]any) map[string]string {
+	ret := make(map[string]string, len(pairs))
+	for k, v := range pairs {
+		if s, ok := v.(string); ok {
+			ret[k] = s
+			continue
+		}
+		buf, err := json.Marshal(v)
+		if err != nil {
+			ret[k] = fmt.Sprintf("%v", v)
+			continue
+		}
+		ret[k] = string(buf)
+	}
+	return ret
+}
+
+// FromStatus converts the gRPC status back to the error.
+//
+// The statuses produced by ToStatus are rebuilt from their errors.WireError (see errors.Unmarshal),
+// so errors.Is matches them against the Coders registered in the catalogue.
+// The rest become Coded errors typed after the ErrorInfo's reason, if any,
+// their HTTP code is derived from the gRPC one; the ErrorInfo's metadata is attached
+// as string key-value pairs.
+// OK or nil status returns a nil error.
+func FromStatus(st *status.Status) error {
+	if st == nil || st.Code() == codes.OK {
+		return nil
+	}
+
+	var info *errdetails.ErrorInfo
+	for _, d := range st.Details() {
+		if i, ok := d.(*errdetails.ErrorInfo); ok {
+			info = i
+			break
+		}
+	}
+	if raw, ok := info.GetMetadata()[WireKey]; ok {
+		if err, uerr := errors.Unmarshal([]byte(raw)); uerr == nil && errors.Code(err) != nil {
+			return err
+		}
+	}
+
+	c := errors.NewCoder(info.GetReason()).
+		WithHTTPCode(errors.HTTPCodeFromGRPC(st.Code())).
+		WithGRPCCode(st.Code())
+	if ri := retryInfo(st); ri != nil && ri.RetryDelay.AsDuration() > 0 {
+		c = c.RetryAfter(ri.RetryDelay.AsDuration())
+	}
+
+	cause := errors.New(st.Message())
+	keys := make([]string, 0, len(info.GetMetadata()))
+	for k := range info.GetMetadata() {
+		if k != WireKey {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		cause = errorbag.With(cause, k, info.Metadata[k])
+	}
+	return c.Wrap(cause)
+}
+
+// FromError converts the error returned by a gRPC client; see FromStatus.
+// The errors without a gRPC status are returned as is.
+func FromError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return FromStatus(st)
+}
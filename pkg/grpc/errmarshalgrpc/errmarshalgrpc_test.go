@@ -0,0 +1,122 @@
+package errmarshalgrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type conflictDetail struct {
+	ID string `json:"id"`
+}
+
+var (
+	errNotFound   = errors.NewCoder("NOT_FOUND").WithHTTPCode(404).WithMessage("not found")
+	errConflict   = errors.NewCoderDetailer[conflictDetail]("CONFLICT").WithHTTPCode(409).WithGRPCCode(codes.Aborted)
+	errRegistered = errors.Register(errors.NewCoderDetailer[conflictDetail]("TEST_GRPC_CONFLICT").WithHTTPCode(409))
+)
+
+func TestToStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("coded", func(t *testing.T) {
+		so := require.New(t)
+
+		err := errors.Wrapd(errNotFound.Wrap(errors.New("no rows")), "get item", "query", "SELECT 1")
+		st := ToStatus(ctx, err)
+		so.Equal(codes.NotFound, st.Code())
+		so.Equal("not found", st.Message())
+
+		so.Len(st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+		so.True(ok)
+		so.Equal("NOT_FOUND", info.Reason)
+		so.JSONEq(`{"type":"NOT_FOUND","http_code":404,"message":"not found"}`, info.Metadata[WireKey])
+		so.NotContains(info.Metadata, "query")
+		so.NotContains(info.Metadata, "errors.Coded")
+	})
+	t.Run("details", func(t *testing.T) {
+		so := require.New(t)
+
+		st := ToStatus(ctx, errConflict.Wrap(errors.New("dup"), conflictDetail{ID: "a1"}))
+		so.Equal(codes.Aborted, st.Code())
+		info := st.Details()[0].(*errdetails.ErrorInfo)
+		so.JSONEq(`{"id":"a1"}`, info.Metadata["errmarshalgrpc.conflictDetail"])
+	})
+	t.Run("uncoded", func(t *testing.T) {
+		so := require.New(t)
+
+		st := ToStatus(ctx, errors.New("pq: relation users does not exist"))
+		so.Equal(codes.Internal, st.Code())
+		so.Equal("internal error", st.Message())
+
+		sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}})
+		st = ToStatus(trace.ContextWithSpanContext(ctx, sc), errors.New("boom"))
+		so.Equal("internal error, trace ID 01000000000000000000000000000000", st.Message())
+
+		so.Equal(codes.Unavailable, ToStatus(ctx, status.Error(codes.Unavailable, "down")).Code())
+		so.Nil(ToStatus(ctx, nil))
+	})
+	t.Run("exposed", func(t *testing.T) {
+		so := require.New(t)
+		errors.SetExposeInternal(true)
+		t.Cleanup(func() { errors.SetExposeInternal(false) })
+
+		st := ToStatus(ctx, errors.Wrapd(errNotFound.Wrap(errors.New("no rows")), "get item", "query", "SELECT 1"))
+		so.Equal("get item: no rows", st.Message())
+		so.Equal("SELECT 1", st.Details()[0].(*errdetails.ErrorInfo).Metadata["query"])
+
+		so.Equal("boom", ToStatus(ctx, errors.New("boom")).Message())
+	})
+}
+
+func TestFromStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("roundtrip", func(t *testing.T) {
+		so := require.New(t)
+
+		err := FromError(ToStatus(ctx, errNotFound.Wrap(errors.New("no rows"))).Err())
+		so.True(errors.Is(err, errNotFound))
+		so.False(errors.Is(err, errNotFound.WithGRPCCode(codes.Unavailable)))
+		so.Equal("not found", err.Error())
+
+		err = FromError(ToStatus(ctx, errConflict.Wrap(errors.New("dup"), conflictDetail{ID: "a1"})).Err())
+
+		code := errors.Code(err)
+		so.Equal(409, code.HTTPCode())
+		so.Equal(codes.Aborted, errors.PolicyOf(code).GRPCCode())
+
+		detail, ok := errors.KeyedData[string, string](err, "errmarshalgrpc.conflictDetail")
+		so.True(ok)
+		so.JSONEq(`{"id":"a1"}`, detail)
+	})
+	t.Run("registered details", func(t *testing.T) {
+		so := require.New(t)
+
+		err := FromError(ToStatus(ctx, errRegistered.Wrap(errors.New("dup"), conflictDetail{ID: "b2"})).Err())
+		so.Equal(&conflictDetail{ID: "b2"}, errRegistered.ExtractDetail(err))
+	})
+	t.Run("foreign", func(t *testing.T) {
+		so := require.New(t)
+
+		st, err := status.New(codes.PermissionDenied, "nope").WithDetails(&errdetails.ErrorInfo{Reason: "FORBIDDEN"})
+		so.NoError(err)
+		code := errors.Code(FromStatus(st))
+		so.Equal("FORBIDDEN", code.Type())
+		so.Equal(403, code.HTTPCode())
+		so.Equal(codes.PermissionDenied, errors.PolicyOf(code).GRPCCode())
+
+		code = errors.Code(FromStatus(status.New(codes.DeadlineExceeded, "slow")))
+		so.Equal(504, code.HTTPCode())
+		so.Equal("", code.Type())
+
+		so.NoError(FromStatus(status.New(codes.OK, "")))
+	})
+}
@@ -0,0 +1,125 @@
+/*
+Package accesslog provides an HTTP middleware which logs every served request via [github.com/utrack/caisson-go/log].
+
+It emits a single record per request with the method, route pattern, status, sizes, duration, remote IP, user agent and trace ID.
+5xx responses are logged with the Error level, 4xx with Warn and the rest with Info.
+
+The request's context is enriched via log.With(), so that the handlers' logs carry the same request fields.
+*/
+package accesslog
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/utrack/caisson-go/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configure the access logger.
+type Options struct {
+	// DropRatio is the ratio of the successful (<400) requests not being logged, from 0 to 1;
+	// zero logs them all. Failed requests are always logged.
+	DropRatio float64
+	// ExcludePaths are the request paths which are not logged at all.
+	// A path ending with '*' excludes every path with that prefix.
+	ExcludePaths []string
+}
+
+// Middleware returns the access logging middleware.
+//
+// It should be placed after the tracing middleware, so that the records carry the trace ID.
+func Middleware(o Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if o.excluded(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+
+			ctx := log.With(r.Context(),
+				"http.method", r.Method,
+				"http.path", r.URL.Path,
+				"http.remote_ip", r.RemoteAddr,
+				"http.user_agent", r.UserAgent(),
+			)
+			// slogtrace adds the trace ID for the recorded spans only;
+			// add it for the unsampled ones as well, so that the requests can be correlated with the upstream
+			if sc := trace.SpanFromContext(ctx); !sc.IsRecording() && sc.SpanContext().HasTraceID() {
+				ctx = log.With(ctx, "trace_id", sc.SpanContext().TraceID().String())
+			}
+
+			body := &countingReader{ReadCloser: r.Body}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = body
+			}
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status < 400 && o.DropRatio > 0 && rand.Float64() < o.DropRatio {
+				return
+			}
+
+			// the handler might not read the body at all
+			reqSize := max(body.n, r.ContentLength)
+
+			kvs := []any{
+				"http.status", status,
+				"http.request_size", reqSize,
+				"http.response_size", ww.BytesWritten(),
+				"http.duration_ms", float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				kvs = append(kvs, "http.route", rctx.RoutePattern())
+			}
+
+			switch {
+			case status >= 500:
+				log.Errorn(ctx, "http request served", kvs...)
+			case status >= 400:
+				log.Warn(ctx, "http request served", kvs...)
+			default:
+				log.Info(ctx, "http request served", kvs...)
+			}
+		})
+	}
+}
+
+func (o Options) excluded(path string) bool {
+	for _, p := range o.ExcludePaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+			continue
+		}
+		if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,52 @@
+package accesslog_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/log/logtest"
+	"github.com/utrack/caisson-go/pkg/http/accesslog"
+)
+
+func TestMiddleware(t *testing.T) {
+	so := require.New(t)
+	ctx, logs := logtest.New(t)
+
+	r := chi.NewRouter()
+	r.Use(accesslog.Middleware(accesslog.Options{ExcludePaths: []string{"/livez"}}))
+	r.Post("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		log.Info(r.Context(), "inside the handler")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("nope"))
+	})
+	r.Get("/livez", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest("POST", "/users/42", strings.NewReader("hello")).WithContext(ctx)
+	req.Header.Set("User-Agent", "test-agent")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	logs.AssertLogged(t, slog.LevelInfo, "inside the handler", "http.method", "POST", "http.path", "/users/42")
+	logs.AssertLogged(t, slog.LevelWarn, "http request served",
+		"http.method", "POST",
+		"http.route", "/users/{id}",
+		"http.status", 404,
+		"http.request_size", 5,
+		"http.response_size", 4,
+		"http.user_agent", "test-agent",
+	)
+
+	logs.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/livez", nil).WithContext(ctx))
+	so.Empty(logs.Records())
+
+	// the zero options log the successful requests as well
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil).WithContext(ctx))
+	logs.AssertLogged(t, slog.LevelInfo, "http request served", "http.status", 200)
+}
@@ -0,0 +1,146 @@
+/*
+Package debugmode provides HTTP middlewares which turn on the full visibility for a single request.
+
+A request carrying a valid debug token in a header is always sampled by the tracer,
+logs with the Debug level and gets its trace ID echoed back in the X-Debug-Trace-Id header.
+Other requests are left untouched.
+
+The token is either one of the allowlisted static tokens, or a token signed with a shared secret via [Sign].
+*/
+package debugmode
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/utrack/caisson-go/levels/level3/logctx"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/pkg/observe/tracer"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultHeader is the request header carrying the debug token.
+const DefaultHeader = "X-Debug-Token"
+
+// TraceIDHeader is the response header the trace ID is echoed in.
+const TraceIDHeader = "X-Debug-Trace-Id"
+
+// Options configure the debug token verification.
+//
+// Debug mode is disabled if both Tokens and Secret are empty.
+type Options struct {
+	// Header is the request header carrying the token. Defaults to [DefaultHeader].
+	Header string
+	// Tokens are the allowlisted static tokens.
+	Tokens []string
+	// Secret is the key for the tokens issued via [Sign].
+	Secret []byte
+}
+
+type ctxDebugKeyType string
+
+const ctxDebugKey ctxDebugKeyType = "caisson.debugmode"
+
+// Enabled reports whether the request with this context is in debug mode.
+func Enabled(ctx context.Context) bool {
+	ret, _ := ctx.Value(ctxDebugKey).(bool)
+	return ret
+}
+
+// Middleware turns on the debug mode for the requests carrying a valid debug token.
+//
+// It should be placed before the tracing middleware, so that the request's span is sampled.
+// The token header is removed from the request before it is passed down.
+func Middleware(o Options) func(http.Handler) http.Handler {
+	if o.Header == "" {
+		o.Header = DefaultHeader
+	}
+	return func(next http.Handler) http.Handler {
+		if len(o.Tokens) == 0 && len(o.Secret) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(o.Header)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Header.Del(o.Header)
+
+			ctx := r.Context()
+			if !o.verify(token, time.Now()) {
+				// any client is able to send a bogus token, so it is not worth a warning
+				log.Debug(ctx, "debugmode: invalid debug token, ignoring", "header", o.Header)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx = context.WithValue(ctx, ctxDebugKey, true)
+			ctx = tracer.ForceSample(ctx)
+			ctx = logctx.WithLevel(ctx, slog.LevelDebug)
+			ctx = log.With(ctx, "debug_request", true)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// EchoTraceID sets the [TraceIDHeader] response header for the requests in debug mode.
+//
+// It should be placed after the tracing middleware.
+func EchoTraceID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Enabled(r.Context()) {
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				w.Header().Set(TraceIDHeader, sc.TraceID().String())
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Sign issues a debug token valid until expiresAt.
+//
+// The token has the form of `<unix expiry>.<hex HMAC-SHA256 of the expiry>`.
+func Sign(secret []byte, expiresAt time.Time) string {
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+	return exp + "." + hex.EncodeToString(mac(secret, exp))
+}
+
+func (o Options) verify(token string, now time.Time) bool {
+	for _, t := range o.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	if len(o.Secret) == 0 {
+		return false
+	}
+
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > expUnix {
+		return false
+	}
+	gotSig, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(gotSig, mac(o.Secret, exp))
+}
+
+func mac(secret []byte, payload string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
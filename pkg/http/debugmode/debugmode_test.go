@@ -0,0 +1,55 @@
+package debugmode
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/levels/level3/logctx"
+	"github.com/utrack/caisson-go/pkg/observe/tracer"
+)
+
+func TestOptions_verify(t *testing.T) {
+	so := require.New(t)
+	now := time.Now()
+	o := Options{Tokens: []string{"static"}, Secret: []byte("secret")}
+
+	so.True(o.verify("static", now))
+	so.True(o.verify(Sign([]byte("secret"), now.Add(time.Minute)), now))
+
+	so.False(o.verify("other", now))
+	so.False(o.verify(Sign([]byte("secret"), now.Add(-time.Minute)), now), "expired token")
+	so.False(o.verify(Sign([]byte("wrong"), now.Add(time.Minute)), now), "wrong secret")
+}
+
+func TestMiddleware(t *testing.T) {
+	so := require.New(t)
+
+	var gotDebug, gotSample bool
+	var gotLevel slog.Leveler
+	var gotToken string
+	hdl := Middleware(Options{Tokens: []string{"static"}})(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotDebug = Enabled(r.Context())
+		gotSample = tracer.IsForcedSample(r.Context())
+		gotLevel, _ = logctx.LevelFrom(r.Context())
+		gotToken = r.Header.Get(DefaultHeader)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(DefaultHeader, "static")
+	hdl.ServeHTTP(httptest.NewRecorder(), req)
+
+	so.True(gotDebug)
+	so.True(gotSample)
+	so.Equal(slog.LevelDebug, gotLevel.Level())
+	so.Empty(gotToken, "token should not be passed down")
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(DefaultHeader, "invalid")
+	hdl.ServeHTTP(httptest.NewRecorder(), req)
+	so.False(gotDebug)
+	so.False(gotSample)
+}
@@ -3,25 +3,38 @@ package errmarshalhttp
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/longkai/rfc7807"
 	"github.com/utrack/caisson-go/errors"
 	"github.com/utrack/caisson-go/levels/level3/errorbag"
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// TODO move context away, write span somewhere else
+// TraceIDExtension is the problem's extension carrying the trace ID of the uncoded errors
+// when the internal details are hidden.
+const TraceIDExtension = "trace_id"
+
+// SetHeaders sets the response headers derived from the error: Retry-After, in seconds,
+// if the error's Coder was marked via RetryAfter.
+func SetHeaders(h http.Header, err error) {
+	if d, ok := errors.RetryAfter(err); ok {
+		h.Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+	}
+}
+
+// ToRFC7807 converts the error to the problem document.
+// The internal details are hidden unless errors.ExposeInternal is on.
+// The context is consulted for the trace ID only; the callers record the error on the span
+// and metrics themselves (see errobserve.Record).
 func ToRFC7807(ctx context.Context, rspErr error) *rfc7807.ProblemDetail {
 	if rspErr == nil {
 		return nil
 	}
-	span := trace.SpanFromContext(ctx)
-	if span != nil {
-		span.RecordError(rspErr)
-	}
 
+	expose := errors.ExposeInternal()
 	code := errors.Code(rspErr)
 
 	var rsp rfc7807.ProblemDetail
@@ -29,22 +42,26 @@ func ToRFC7807(ctx context.Context, rspErr error) *rfc7807.ProblemDetail {
 		rsp.Status = http.StatusInternalServerError
 		rsp.Detail = rspErr.Error()
 	} else {
-
 		rsp.Status = code.HTTPCode()
 		rsp.Type = code.Type()
 		rsp.Title = code.Message()
 		rsp.Detail = rspErr.Error()
-
 	}
 
-	pairs := errorbag.ListPairs(rspErr)
-	rsp.Extensions = pairs
-
-	if span != nil {
-		for k, v := range pairs {
-			// TODO: convert to attribute.Value
-			span.SetAttributes(attribute.String(k, fmt.Sprintf("%v", v)))
+	if expose {
+		rsp.Extensions = errorbag.ListPairs(rspErr)
+	} else {
+		public := errorbag.ListPublicPairs(rspErr)
+		rsp.Detail = ""
+		if code == nil {
+			rsp.Title = http.StatusText(http.StatusInternalServerError)
+			rsp.Detail = "internal error"
+			if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+				rsp.Detail = fmt.Sprintf("internal error, trace ID %v", sc.TraceID())
+				public[TraceIDExtension] = sc.TraceID().String()
+			}
 		}
+		rsp.Extensions = public
 	}
 
 	return &rsp
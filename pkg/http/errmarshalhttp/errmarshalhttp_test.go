@@ -0,0 +1,65 @@
+package errmarshalhttp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errNotFound = errors.NewCoder("NOT_FOUND").WithHTTPCode(404).WithMessage("not found")
+
+func TestToRFC7807(t *testing.T) {
+	ctx := context.Background()
+	coded := errors.WithPublicData(
+		errors.Wrapd(errNotFound.Wrap(errors.New("no rows")), "get item", "query", "SELECT 1"),
+		"item_id", "a1")
+
+	t.Run("hidden", func(t *testing.T) {
+		so := require.New(t)
+
+		p := ToRFC7807(ctx, coded)
+		so.Equal(404, p.Status)
+		so.Equal("NOT_FOUND", p.Type)
+		so.Equal("not found", p.Title)
+		so.Empty(p.Detail)
+		ext := p.Extensions.(map[string]any)
+		so.Equal("a1", ext["item_id"])
+		so.NotContains(ext, "errors.Coded")
+		so.NotContains(ext, "query")
+
+		sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}})
+		p = ToRFC7807(trace.ContextWithSpanContext(ctx, sc), errors.New("pq: relation users does not exist"))
+		so.Equal(500, p.Status)
+		so.Equal("internal error, trace ID 01000000000000000000000000000000", p.Detail)
+		so.Equal(map[string]any{TraceIDExtension: "01000000000000000000000000000000"}, p.Extensions)
+	})
+	t.Run("exposed", func(t *testing.T) {
+		so := require.New(t)
+		errors.SetExposeInternal(true)
+		t.Cleanup(func() { errors.SetExposeInternal(false) })
+
+		p := ToRFC7807(ctx, coded)
+		so.Equal("get item: no rows", p.Detail)
+		so.Equal("SELECT 1", p.Extensions.(map[string]any)["query"])
+
+		p = ToRFC7807(ctx, errors.New("boom"))
+		so.Equal("boom", p.Detail)
+	})
+}
+
+func TestSetHeaders(t *testing.T) {
+	so := require.New(t)
+
+	h := http.Header{}
+	SetHeaders(h, errors.NewCoder("THROTTLED").WithHTTPCode(429).RetryAfter(1500*time.Millisecond).Wrap(errors.New("x")))
+	so.Equal("2", h.Get("Retry-After"))
+
+	h = http.Header{}
+	SetHeaders(h, errNotFound.Wrap(errors.New("x")))
+	so.Empty(h)
+}
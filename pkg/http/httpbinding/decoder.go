@@ -1,43 +1,253 @@
 package httpbinding
 
 import (
-	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"reflect"
+	"strings"
 
 	"github.com/ggicci/httpin"
+	"github.com/ggicci/httpin/core"
 	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/validate"
 )
 
-// requestDecoder decodes a request body into a type T.
-type requestDecoder interface {
-	Decode(req *http.Request) (any, error)
+var ErrRequestTooLarge = errors.Register(errors.NewCoder("REQUEST_TOO_LARGE").WithHTTPCode(http.StatusRequestEntityTooLarge).WithMessage("request body is too large"))
+
+// multipartMaxMemory is the size of the multipart body kept in memory by ParseMultipartForm;
+// the rest of the files are stored on disk.
+const multipartMaxMemory = 32 << 20
+
+// inputDecoder decodes the requests into the handler's input type.
+//
+// The fields tagged with `in` are extracted by httpin (path, query, headers etc.);
+// the untagged ones are decoded from the request body according to its Content-Type:
+// form-urlencoded, multipart or any format registered in negmarshal.
+// Types using httpin's body directive are decoded by httpin alone.
+//
+// Multipart bodies are streamed into the File fields according to the UploadLimits
+// of the binding, overridden by the input type implementing UploadLimiter.
+//
+// The decoded values are validated against their `validate` tags; see package validate.
+type inputDecoder struct {
+	t         reflect.Type
+	httpin    *core.Core
+	validator *validate.Validator
+	// decodeBody is set if the untagged fields are decoded from the body
+	decodeBody bool
+	// fileHeaders is set if the multipart bodies are parsed via http.Request.ParseMultipartForm
+	fileHeaders bool
+	uploads     UploadLimits
+	opts        options
 }
 
-// jsonBodyDecoder decodes a request body using JSON body only.
-type jsonBodyDecoder struct {
-	t reflect.Type
+func newInputDecoder(t reflect.Type, opts options) (*inputDecoder, error) {
+	d := &inputDecoder{t: t, opts: opts}
+
+	dirs := scanDirectives(t)
+	if dirs.tagged {
+		engine, err := httpin.New(reflect.New(t).Interface())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create HTTPin decoder")
+		}
+		d.httpin = engine
+	}
+	d.decodeBody = dirs.untagged && !dirs.body
+	d.fileHeaders = hasFileHeaders(t)
+
+	d.uploads = opts.uploads
+	if l, ok := reflect.New(t).Interface().(UploadLimiter); ok {
+		d.uploads = d.uploads.merge(l.UploadLimits())
+	}
+
+	v, err := validate.Compile(t)
+	if err != nil {
+		return nil, err
+	}
+	if !v.Empty() {
+		d.validator = v
+	}
+	return d, nil
 }
 
-func newJsonBodyDecoder(t reflect.Type) requestDecoder {
-	return jsonBodyDecoder{t: t}
+func (d *inputDecoder) Decode(w http.ResponseWriter, r *http.Request) (reflect.Value, error) {
+	maxBodySize := d.opts.maxBodySize
+	if d.uploads.MaxBodySize != 0 {
+		maxBodySize = d.uploads.MaxBodySize
+	}
+	if maxBodySize > 0 && r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	}
+
+	v := reflect.New(d.t)
+	if d.decodeBody && hasBody(r) {
+		if err := d.decodeBodyTo(r, v.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		// the codecs fill any field they can match; the httpin's ones must come
+		// from their sources only, httpin leaves them be if the source is missing
+		zeroTagged(v.Elem())
+	}
+	if d.httpin != nil {
+		if err := d.httpin.DecodeTo(r, v.Interface()); err != nil {
+			var invalidFieldError *core.InvalidFieldError
+			switch {
+			case isTooLarge(err):
+				return reflect.Value{}, ErrRequestTooLarge.Wrap(err)
+			case errors.As(err, &invalidFieldError):
+				return reflect.Value{}, ErrMalformedRequest.Wrap(err)
+			}
+			return reflect.Value{}, errors.Wrap(err, "failed to decode HTTPin request")
+		}
+	}
+	if d.validator != nil {
+		if err := d.validator.Validate(v.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return v.Elem(), nil
 }
 
-func (j jsonBodyDecoder) Decode(req *http.Request) (any, error) {
-	out := reflect.New(j.t).Interface()
-	if err := json.NewDecoder(req.Body).Decode(out); err != nil {
-		return nil, errors.Wrap(err, "failed to decode JSON request body")
+func (d *inputDecoder) decodeBodyTo(r *http.Request, v any) error {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		ct = "application/json"
 	}
-	return out, nil
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return negmarshal.ErrUnsupportedMediaType.Wrap(errors.Wrapf(err, "malformed content type '%v'", ct))
+	}
+
+	switch mt {
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return bodyError(err, mt)
+		}
+		return bodyError(decodeForm(form{values: r.PostForm}, v, d.opts.disallowUnknownFields), mt)
+	case "multipart/form-data":
+		if d.fileHeaders {
+			if err := r.ParseMultipartForm(multipartMaxMemory); err != nil {
+				return bodyError(err, mt)
+			}
+			return bodyError(decodeForm(form{values: r.MultipartForm.Value, headers: r.MultipartForm.File}, v, d.opts.disallowUnknownFields), mt)
+		}
+		fm, err := readMultipart(r, d.uploads)
+		if err != nil {
+			return bodyError(err, mt)
+		}
+		// the body is consumed; let httpin's form directives see the values
+		r.MultipartForm = &multipart.Form{Value: fm.values}
+		r.PostForm = fm.values
+		return bodyError(decodeForm(fm, v, d.opts.disallowUnknownFields), mt)
+	}
+
+	c, ok := negmarshal.CodecFor(mt)
+	if !ok {
+		return negmarshal.ErrUnsupportedMediaType.Wrap(errors.Errorf("no decoder registered for content type '%v'", mt))
+	}
+	if sc, ok := c.(negmarshal.StrictCodec); ok && d.opts.disallowUnknownFields {
+		err = sc.DecodeStrict(r.Body, v)
+	} else {
+		err = c.Decode(r.Body, v)
+	}
+	if errors.Is(err, io.EOF) {
+		// empty chunked body
+		return nil
+	}
+	return bodyError(err, mt)
 }
 
-func newHttpinDecoder(t reflect.Type) (requestDecoder, error) {
-	inValue := reflect.New(t).Interface()
+// bodyError converts the body decoding error to a Coded one.
+func bodyError(err error, contentType string) error {
+	switch {
+	case err == nil:
+		return nil
+	case isTooLarge(err):
+		return ErrRequestTooLarge.Wrap(err)
+	case errors.Code(err) != nil:
+		return err
+	}
+	return ErrMalformedRequest.Wrap(errors.Wrapf(err, "when decoding %v request body", contentType))
+}
 
-	unmEngine, err := httpin.New(inValue)
-	if err != nil {
-		return nil, err
+func isTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// zeroTagged resets the fields tagged with `in`, including the embedded structs' ones.
+func zeroTagged(rv reflect.Value) {
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
 	}
 
-	return unmEngine, nil
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("in"); ok {
+			if fv := rv.Field(i); fv.CanSet() {
+				fv.SetZero()
+			}
+			continue
+		}
+		if f.Anonymous {
+			zeroTagged(rv.Field(i))
+		}
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+type directives struct {
+	// tagged is set if any field has the httpin's `in` tag
+	tagged bool
+	// body is set if httpin decodes the body itself
+	body bool
+	// untagged is set if any field is left for the body decoder
+	untagged bool
+}
+
+// scanDirectives looks up the httpin directives of the input type.
+func scanDirectives(t reflect.Type) directives {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return directives{untagged: true}
+	}
+
+	var ret directives
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("in")
+		switch {
+		case ok:
+			ret.tagged = true
+			for _, dir := range strings.Split(tag, ";") {
+				name, _, _ := strings.Cut(strings.TrimSpace(dir), "=")
+				if name == "body" {
+					ret.body = true
+				}
+			}
+		case f.Anonymous:
+			sub := scanDirectives(f.Type)
+			ret.tagged = ret.tagged || sub.tagged
+			ret.body = ret.body || sub.body
+			ret.untagged = ret.untagged || sub.untagged
+		case f.IsExported():
+			ret.untagged = true
+		}
+	}
+	return ret
 }
@@ -0,0 +1,196 @@
+package httpbinding
+
+import (
+	"encoding"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/utrack/caisson-go/errors"
+)
+
+var (
+	typeFileHeader  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	typeFileHeaders = reflect.TypeOf([]*multipart.FileHeader(nil))
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// form is the decoded form body.
+type form struct {
+	values map[string][]string
+	// headers are the files parsed by http.Request.ParseMultipartForm
+	headers map[string][]*multipart.FileHeader
+	// files are the files streamed by readMultipart
+	files map[string][]*File
+}
+
+// decodeForm decodes the form values and files into the untagged fields of the struct v points to.
+//
+// The fields are named after their json tags, or the fields' names if there are none.
+// Files are decoded into *File and []*File fields, or *multipart.FileHeader and []*multipart.FileHeader ones.
+func decodeForm(fm form, v any, strict bool) error {
+	rv := reflect.ValueOf(v).Elem()
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("form bodies can't be decoded into %v", rv.Type())
+	}
+
+	known := map[string]struct{}{}
+	if err := decodeFormStruct(rv, fm, known); err != nil {
+		return err
+	}
+	if !strict {
+		return nil
+	}
+	names := []string{}
+	for k := range fm.values {
+		names = append(names, k)
+	}
+	for k := range fm.headers {
+		names = append(names, k)
+	}
+	for k := range fm.files {
+		names = append(names, k)
+	}
+	for _, k := range names {
+		if _, ok := known[k]; !ok {
+			return errors.Errorf("unknown field '%v'", k)
+		}
+	}
+	return nil
+}
+
+func decodeFormStruct(rv reflect.Value, fm form, known map[string]struct{}) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("in"); ok {
+			// httpin's field
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := decodeFormStruct(rv.Field(i), fm, known); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := formName(f)
+		if name == "" {
+			continue
+		}
+		known[name] = struct{}{}
+
+		fv := rv.Field(i)
+		switch f.Type {
+		case typeFile:
+			if fs := fm.files[name]; len(fs) > 0 {
+				fv.Set(reflect.ValueOf(fs[0]))
+			}
+			continue
+		case typeFiles:
+			fv.Set(reflect.ValueOf(fm.files[name]))
+			continue
+		case typeFileHeader:
+			if fhs := fm.headers[name]; len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs[0]))
+			}
+			continue
+		case typeFileHeaders:
+			fv.Set(reflect.ValueOf(fm.headers[name]))
+			continue
+		}
+
+		vals, ok := fm.values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setFormValue(fv, vals); err != nil {
+			return errors.Wrapf(err, "field '%v'", name)
+		}
+	}
+	return nil
+}
+
+func formName(f reflect.StructField) string {
+	tag, ok := f.Tag.Lookup("json")
+	if !ok {
+		return f.Name
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+func setFormValue(fv reflect.Value, vals []string) error {
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 && !reflect.PointerTo(fv.Type()).Implements(textUnmarshaler) {
+		sl := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setScalar(sl.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(sl)
+		return nil
+	}
+	return setScalar(fv, vals[0])
+}
+
+func setScalar(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setScalar(ptr.Elem(), s); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	default:
+		return errors.Errorf("unsupported form field type %v", fv.Type())
+	}
+	return nil
+}
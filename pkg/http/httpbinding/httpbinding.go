@@ -5,12 +5,14 @@ import (
 	"net/http"
 	"reflect"
 
-	"github.com/ggicci/httpin/core"
 	"github.com/ggicci/httpin/integration"
 	"github.com/go-chi/chi/v5"
 	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
 	"github.com/utrack/caisson-go/pkg/http/errmarshalhttp"
 	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/http/ws"
+	"github.com/utrack/caisson-go/pkg/observe/errobserve"
 	"github.com/utrack/pontoon/sdesc"
 )
 
@@ -18,25 +20,35 @@ var (
 	errorInterface  = reflect.TypeOf((*error)(nil)).Elem()
 	writerInterface = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
 	typeHttpReq     = reflect.TypeOf(&http.Request{})
-	typeCtx         = reflect.TypeOf(context.Context(nil))
+	typeCtx         = reflect.TypeOf((*context.Context)(nil)).Elem()
 )
 
 func init() {
 	integration.UseGochiURLParam("path", chi.URLParam)
 }
 
-var ErrMalformedRequest = errors.NewCoder("BAD_REQUEST").WithHTTPCode(400).WithMessage("malformed request body")
+var ErrMalformedRequest = errors.Register(errors.NewCoder("BAD_REQUEST").WithHTTPCode(400).WithMessage("malformed request body"))
 
 // wrapDescRPCHandler converts sdesc.RPCHandler to stdlib http.HandlerFunc.
 // It can wrap handlers that accept any/all of *http.Request, http.ResponseWriter
-// and any custom struct (which is then unmarshaled via ggicci/httpin).
+// and any custom type. Its fields tagged with `in` are unmarshaled via ggicci/httpin,
+// the rest are decoded from the request body according to its Content-Type.
 //
 // Handlers' output types are either ({return type},error), (error) or nothing.
 // The return type is marshaled to negotiated content type, or JSON by default.
+// It can set the response's status code, headers and cookies via StatusCoder, Headerer and Cookier,
+// or by being a Response.
+//
+// Handlers returning iter.Seq2[T, error] or a receive channel of T are streamed
+// as NDJSON or Server-Sent Events; see writeStream.
+//
+// Handlers accepting *ws.Conn[Req, Resp] as the last parameter and returning an error are WebSocket endpoints;
+// the connection is upgraded after decoding the input and closed after the handler returns, see ws.Socket.CloseError.
+// Their context.Context is canceled when the connection closes.
 //
 // Writing to http.ResponseWriter is not allowed if handler has a return type.
-func BindHTTPHandler(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMarshalFunc) (http.Handler, error) {
-	ret, _, err := BindHTTPHandlerMeta(h, marshaler)
+func BindHTTPHandler(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMarshalFunc, opts ...Option) (http.Handler, error) {
+	ret, _, err := BindHTTPHandlerMeta(h, marshaler, opts...)
 	return ret, err
 }
 
@@ -45,7 +57,9 @@ func BindHTTPHandler(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMarshalF
 // It can be used to inspect the handler's input and output types for documentation autogen.
 //
 // TODO this can be split into two functions, one for meta extraction and one for binding based on the meta.
-func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMarshalFunc) (http.Handler, Meta, error) {
+func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMarshalFunc, opts ...Option) (http.Handler, Meta, error) {
+	bopts := newOptions(opts)
+
 	handleFuncRef := reflect.ValueOf(h)
 	if handleFuncRef.Kind() != reflect.Func {
 		return nil, Meta{}, errors.New("handler is not a function")
@@ -66,6 +80,10 @@ func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMars
 	// we don't control the output anymore.
 	var controlsResponseWriter bool
 
+	// index of the *ws.Conn parameter, and of the contexts to replace with the connection's one
+	wsConnIdx := -1
+	ctxIdxs := []int{}
+
 	// functions that convert i-th input type of a handler function
 	// to reflect.Value() for calling
 	type inFun func(w http.ResponseWriter, r *http.Request) (reflect.Value, error)
@@ -79,6 +97,7 @@ func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMars
 				return reflect.ValueOf(r), nil
 			})
 		case funcType.In(i) == typeCtx:
+			ctxIdxs = append(ctxIdxs, i)
 			inFuncs = append(inFuncs, func(_ http.ResponseWriter, r *http.Request) (reflect.Value, error) {
 				return reflect.ValueOf(r.Context()), nil
 			})
@@ -87,25 +106,26 @@ func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMars
 			inFuncs = append(inFuncs, func(w http.ResponseWriter, r *http.Request) (reflect.Value, error) {
 				return reflect.ValueOf(w), nil
 			})
+		case ws.IsConn(funcType.In(i)):
+			if i != funcType.NumIn()-1 {
+				return nil, Meta{}, errors.New("*ws.Conn should be the handler's last parameter")
+			}
+			if funcType.NumOut() != 1 {
+				return nil, Meta{}, errors.New("WebSocket handler should return an error only")
+			}
+			wsConnIdx = i
+			// the connection is upgraded after the rest of inputs are decoded
+			inFuncs = append(inFuncs, func(_ http.ResponseWriter, _ *http.Request) (reflect.Value, error) {
+				return reflect.Value{}, nil
+			})
 		default:
 			inType = funcType.In(i)
 
-			unmEngine, err := newHttpinDecoder(inType)
+			dec, err := newInputDecoder(inType, bopts)
 			if err != nil {
-				return nil, Meta{}, errors.Wrapf(err, "failed to create HTTPin decoder for type %v %v", inType.PkgPath(), inType.Name())
+				return nil, Meta{}, errors.Wrapf(err, "failed to create decoder for type %v %v", inType.PkgPath(), inType.Name())
 			}
-
-			inFuncs = append(inFuncs, func(_ http.ResponseWriter, r *http.Request) (reflect.Value, error) {
-				in, err := unmEngine.Decode(r)
-				if err != nil {
-					var invalidFieldError *core.InvalidFieldError
-					if errors.As(err, &invalidFieldError) {
-						return reflect.Value{}, ErrMalformedRequest.Wrap(err)
-					}
-					return reflect.Value{}, errors.Wrap(err, "failed to decode HTTPin request")
-				}
-				return reflect.ValueOf(in).Elem(), nil
-			})
+			inFuncs = append(inFuncs, dec.Decode)
 		}
 	}
 	if funcType.NumOut() < 1 && !controlsResponseWriter {
@@ -123,26 +143,50 @@ func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMars
 		WriterIntercepted: controlsResponseWriter,
 	}
 
+	stream, isStream := streamOutput{}, false
 	if funcType.NumOut() > 1 {
-		retMeta.OutputType = funcType.Out(0)
+		stream, isStream = streamOutputOf(funcType.Out(0))
+		if isStream {
+			retMeta.OutputType = stream.itemType
+			retMeta.Streaming = true
+		} else {
+			retMeta.OutputType, retMeta.StatusCodes = outputMeta(funcType.Out(0))
+		}
 	}
 
 	if inType != nil {
 		retMeta.InputType = inType
 	}
+	if wsConnIdx >= 0 {
+		retMeta.WebSocket = true
+		retMeta.ReceiveType, retMeta.OutputType = ws.MessageTypes(funcType.In(wsConnIdx))
+		retMeta.StatusCodes = []int{http.StatusSwitchingProtocols}
+	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStream {
+			// the handler's producers stop once the stream is written, even if the client is still there
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
 		inArgs := []reflect.Value{}
 
 		for _, f := range inFuncs {
 			v, err := f(w, r)
 			if err != nil {
-				marshaler(r, w, nil, errmarshalhttp.ToRFC7807(r.Context(), err))
+				writeError(w, r, marshaler, err)
 				return
 			}
 			inArgs = append(inArgs, v)
 		}
 
+		if wsConnIdx >= 0 {
+			serveWebSocket(w, r, marshaler, handleFuncRef, inArgs, wsConnIdx, ctxIdxs, bopts.webSocket)
+			return
+		}
+
 		out := handleFuncRef.Call(inArgs)
 		if len(out) == 0 {
 			return
@@ -154,22 +198,72 @@ func BindHTTPHandlerMeta(h sdesc.RPCHandler, marshaler negmarshal.NegotiatedMars
 		}
 
 		if !out[errPos].IsNil() {
-			marshaler(r, w, nil, errmarshalhttp.ToRFC7807(r.Context(), out[errPos].Interface().(error)))
+			writeError(w, r, marshaler, out[errPos].Interface().(error))
 			return
 		}
 
 		var err error
 		switch {
+		case isStream:
+			writeStream(r, w, marshaler, stream, out[0], bopts.streamHeartbeat)
 		case hasOutputStruct:
-			err = marshaler(r, w, out[0].Interface(), nil)
+			err = writeOutput(r, w, marshaler, out[0].Interface())
 		case controlsResponseWriter:
 			// do not output anything if the handler controls the writer directly
 		default:
 			err = marshaler(r, w, struct{}{}, nil)
 		}
-		if err != nil {
+		switch {
+		case err == nil:
+		case errors.Is(err, negmarshal.ErrNotAcceptable):
+			// the negotiator has already responded with 406
+		default:
 			err = errors.Wrap(err, "the call succeeded, but failed to marshal the response")
-			marshaler(r, w, nil, errmarshalhttp.ToRFC7807(r.Context(), err))
+			writeError(w, r, marshaler, err)
 		}
 	}), retMeta, nil
 }
+
+// serveWebSocket upgrades the connection and calls the WebSocket handler,
+// closing the connection with its returned error.
+func serveWebSocket(w http.ResponseWriter, r *http.Request, marshaler negmarshal.NegotiatedMarshalFunc, h reflect.Value, inArgs []reflect.Value, connIdx int, ctxIdxs []int, o ws.Options) {
+	sock, err := ws.Accept(w, r, o)
+	if errors.Is(err, ws.ErrShuttingDown) {
+		writeError(w, r, marshaler, err)
+		return
+	}
+	if err != nil {
+		// Accept has already responded
+		log.Warne(r.Context(), "WebSocket upgrade failed", err)
+		return
+	}
+	inArgs[connIdx] = ws.NewConn(h.Type().In(connIdx), sock)
+	for _, i := range ctxIdxs {
+		inArgs[i] = reflect.ValueOf(sock.Context())
+	}
+
+	out := h.Call(inArgs)
+	var herr error
+	if !out[0].IsNil() {
+		herr = out[0].Interface().(error)
+		if !ws.IsClosed(herr) {
+			log.Errorv(sock.Context(), "WebSocket handler failed", herr)
+			errobserve.Record(sock.Context(), herr)
+		}
+	}
+	if err := sock.CloseError(herr); err != nil && !ws.IsClosed(err) {
+		log.Warne(sock.Context(), "failed to close the WebSocket connection", err)
+	}
+}
+
+// writeError marshals the handler's error to the client.
+// There's no one left to return the marshaling errors to, so they are logged.
+func writeError(w http.ResponseWriter, r *http.Request, marshaler negmarshal.NegotiatedMarshalFunc, err error) {
+	log.Errorv(r.Context(), "HTTP handler failed", err)
+	errobserve.Record(r.Context(), err)
+	errmarshalhttp.SetHeaders(w.Header(), err)
+	merr := marshaler(r, w, nil, errmarshalhttp.ToRFC7807(r.Context(), err))
+	if merr != nil && !errors.Is(merr, negmarshal.ErrNotAcceptable) {
+		log.Error(r.Context(), "failed to marshal the error response", merr, "response_error", err.Error())
+	}
+}
@@ -0,0 +1,278 @@
+package httpbinding
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+)
+
+type bodyInput struct {
+	Tenant string `in:"query=tenant"`
+
+	Name  string   `json:"name" xml:"name"`
+	Count int      `json:"count" xml:"count"`
+	Tags  []string `json:"tags" xml:"tags"`
+
+	File *multipart.FileHeader `json:"file" xml:"-"`
+}
+
+func TestBindHTTPHandler_body(t *testing.T) {
+	var got bodyInput
+	hdl, err := BindHTTPHandler(func(_ context.Context, in bodyInput) error {
+		got = in
+		return nil
+	}, negmarshal.ForStyle(negmarshal.StyleRaw), WithMaxBodySize(1024), WithDisallowUnknownFields(true))
+	require.NoError(t, err)
+
+	var mp bytes.Buffer
+	mw := multipart.NewWriter(&mp)
+	_ = mw.WriteField("name", "a")
+	_ = mw.WriteField("count", "2")
+	_ = mw.WriteField("tags", "x")
+	_ = mw.WriteField("tags", "y")
+	fw, _ := mw.CreateFormFile("file", "f.txt")
+	_, _ = fw.Write([]byte("contents"))
+	_ = mw.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"json", "application/json", `{"name":"a","count":2,"tags":["x","y"]}`, 200},
+		{"no content type is json", "", `{"name":"a","count":2,"tags":["x","y"]}`, 200},
+		{"xml", "application/xml", `<bodyInput><name>a</name><count>2</count><tags>x</tags><tags>y</tags></bodyInput>`, 200},
+		{"form", "application/x-www-form-urlencoded", `name=a&count=2&tags=x&tags=y`, 200},
+		{"multipart", mw.FormDataContentType(), mp.String(), 200},
+		{"unsupported", "text/csv", `a,2`, 415},
+		{"malformed", "application/json", `{"name":`, 400},
+		{"unknown json field", "application/json", `{"name":"a","extra":1}`, 400},
+		{"unknown form field", "application/x-www-form-urlencoded", `name=a&extra=1`, 400},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", 2048) + `"}`, 413},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			so := require.New(t)
+			got = bodyInput{}
+
+			req := httptest.NewRequest("POST", "/?tenant=t1", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rsp := httptest.NewRecorder()
+			hdl.ServeHTTP(rsp, req)
+
+			so.Equal(tt.wantStatus, rsp.Code, rsp.Body.String())
+			if tt.wantStatus != 200 {
+				so.Equal("application/problem+json", rsp.Header().Get("Content-Type"))
+				return
+			}
+			so.Equal("t1", got.Tenant)
+			so.Equal("a", got.Name)
+			so.Equal(2, got.Count)
+			so.Equal([]string{"x", "y"}, got.Tags)
+			if got.File != nil {
+				f, err := got.File.Open()
+				so.NoError(err)
+				buf, _ := io.ReadAll(f)
+				so.Equal("contents", string(buf))
+			}
+		})
+	}
+}
+
+type authInput struct {
+	UserID string `in:"header=X-User-Id"`
+	AuthEmbedded
+
+	Name string `json:"name"`
+}
+
+type AuthEmbedded struct {
+	Role string `in:"query=role"`
+}
+
+func TestBindHTTPHandler_bodyCantSetTagged(t *testing.T) {
+	var got authInput
+	hdl, err := BindHTTPHandler(func(_ context.Context, in authInput) error {
+		got = in
+		return nil
+	}, negmarshal.ForStyle(negmarshal.StyleRaw), WithDisallowUnknownFields(true))
+	require.NoError(t, err)
+
+	t.Run("missing sources", func(t *testing.T) {
+		so := require.New(t)
+		got = authInput{}
+
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a","UserID":"admin","Role":"root"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rsp := httptest.NewRecorder()
+		hdl.ServeHTTP(rsp, req)
+
+		so.Equal(200, rsp.Code, rsp.Body.String())
+		so.Equal("a", got.Name)
+		so.Empty(got.UserID)
+		so.Empty(got.Role)
+	})
+	t.Run("sources win", func(t *testing.T) {
+		so := require.New(t)
+		got = authInput{}
+
+		req := httptest.NewRequest("POST", "/?role=viewer", strings.NewReader(`{"name":"a","UserID":"admin","Role":"root"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-Id", "u1")
+		rsp := httptest.NewRecorder()
+		hdl.ServeHTTP(rsp, req)
+
+		so.Equal(200, rsp.Code, rsp.Body.String())
+		so.Equal("u1", got.UserID)
+		so.Equal("viewer", got.Role)
+	})
+}
+
+func TestBindHTTPHandler_plainTypes(t *testing.T) {
+	so := require.New(t)
+
+	var got []string
+	hdl, err := BindHTTPHandler(func(_ context.Context, in []string) error {
+		got = in
+		return nil
+	}, negmarshal.Default())
+	so.NoError(err)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`["a","b"]`))
+	req.Header.Set("Content-Type", "application/json")
+	rsp := httptest.NewRecorder()
+	hdl.ServeHTTP(rsp, req)
+
+	so.Equal(200, rsp.Code)
+	so.Equal([]string{"a", "b"}, got)
+}
+
+func TestBindHTTPHandler_validation(t *testing.T) {
+	so := require.New(t)
+
+	type input struct {
+		Tenant string `in:"query=tenant" validate:"required"`
+		Name   string `json:"name" validate:"required,max=3"`
+	}
+	hdl, err := BindHTTPHandler(func(_ context.Context, in input) error {
+		return nil
+	}, negmarshal.ForStyle(negmarshal.StyleRaw))
+	so.NoError(err)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"long"}`))
+	rsp := httptest.NewRecorder()
+	hdl.ServeHTTP(rsp, req)
+
+	so.Equal(422, rsp.Code)
+	so.Contains(rsp.Body.String(), `"invalid_fields":[{"pointer":"/tenant","reason":"is required"},{"pointer":"/name","reason":"must be at most 3 characters long"}]`)
+}
+
+type accepted struct {
+	ID string `json:"id"`
+}
+
+func (accepted) StatusCode() int { return 202 }
+
+type createdItem struct {
+	ID string `json:"id"`
+}
+
+func (createdItem) ResponseStatuses() []int { return []int{200, 201} }
+
+type noContent struct{}
+
+func (noContent) ResponseStatuses() []int { return []int{204} }
+
+func TestBindHTTPHandler_responseMeta(t *testing.T) {
+	type item struct {
+		ID string `json:"id"`
+	}
+
+	tests := []struct {
+		name         string
+		h            any
+		wantStatus   int
+		wantBody     string
+		wantStatuses []int
+	}{
+		{
+			name: "response wrapper",
+			h: func(context.Context) (Response[createdItem], error) {
+				return Response[createdItem]{
+					Body:       createdItem{ID: "1"},
+					Status:     201,
+					Header:     http.Header{"Location": {"/items/1"}},
+					SetCookies: []*http.Cookie{{Name: "seen", Value: "1"}},
+				}, nil
+			},
+			wantStatus:   201,
+			wantBody:     `{"id":"1"}`,
+			wantStatuses: []int{200, 201},
+		},
+		{
+			name:       "response wrapper without statuses",
+			h:          func(context.Context) (Response[item], error) { return Response[item]{Body: item{ID: "1"}}, nil },
+			wantStatus: 200,
+			wantBody:   `{"id":"1"}`,
+		},
+		{
+			name: "response wrapper with status coder body",
+			h: func(context.Context) (Response[accepted], error) {
+				return Response[accepted]{Body: accepted{ID: "2"}}, nil
+			},
+			wantStatus:   202,
+			wantBody:     `{"id":"2"}`,
+			wantStatuses: []int{202},
+		},
+		{
+			name:         "status coder",
+			h:            func(context.Context) (accepted, error) { return accepted{ID: "2"}, nil },
+			wantStatus:   202,
+			wantBody:     `{"id":"2"}`,
+			wantStatuses: []int{202},
+		},
+		{
+			name:         "no content",
+			h:            func(context.Context) (Response[noContent], error) { return Response[noContent]{Status: 204}, nil },
+			wantStatus:   204,
+			wantStatuses: []int{204},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			so := require.New(t)
+			hdl, meta, err := BindHTTPHandlerMeta(tt.h, negmarshal.ForStyle(negmarshal.StyleRaw))
+			so.NoError(err)
+			so.Equal(tt.wantStatuses, meta.StatusCodes)
+
+			rsp := httptest.NewRecorder()
+			hdl.ServeHTTP(rsp, httptest.NewRequest("GET", "/", nil))
+
+			so.Equal(tt.wantStatus, rsp.Code)
+			so.Equal(tt.wantBody, strings.TrimSpace(rsp.Body.String()))
+		})
+	}
+
+	so := require.New(t)
+	_, meta, err := BindHTTPHandlerMeta(tests[0].h, negmarshal.Default())
+	so.NoError(err)
+	so.Equal(reflect.TypeFor[createdItem](), meta.OutputType)
+
+	rsp := httptest.NewRecorder()
+	hdl, _ := BindHTTPHandler(tests[0].h, negmarshal.Default())
+	hdl.ServeHTTP(rsp, httptest.NewRequest("GET", "/", nil))
+	so.Equal("/items/1", rsp.Header().Get("Location"))
+	so.Equal("seen=1", rsp.Header().Get("Set-Cookie"))
+}
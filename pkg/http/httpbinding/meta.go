@@ -5,8 +5,18 @@ import "reflect"
 // Meta is the metadata of a bound HTTP handler.
 // It is used for later documentation generation.
 type Meta struct {
-	InputType  reflect.Type
+	InputType reflect.Type
+	// OutputType is the type of the marshaled output; for Response[T] it is T.
 	OutputType reflect.Type
+	// StatusCodes are the output's documented status codes; empty means 200 OK.
+	// See StatusCoder and StatusDocumenter.
+	StatusCodes []int
+	// Streaming is set for the handlers streaming their outputs; OutputType is the stream's item type then.
+	Streaming bool
+	// WebSocket is set for the handlers accepting *ws.Conn; OutputType and ReceiveType are the connection's messages then.
+	WebSocket bool
+	// ReceiveType is the type of the WebSocket's received messages.
+	ReceiveType reflect.Type
 
 	NamedFunc any
 
@@ -0,0 +1,69 @@
+package httpbinding
+
+import (
+	"time"
+
+	"github.com/utrack/caisson-go/pkg/http/ws"
+)
+
+// DefaultMaxBodySize is the default limit of the request body size.
+const DefaultMaxBodySize = 10 << 20
+
+// Option configures the handler binding.
+type Option func(*options)
+
+type options struct {
+	maxBodySize           int64
+	disallowUnknownFields bool
+	streamHeartbeat       time.Duration
+	webSocket             ws.Options
+	uploads               UploadLimits
+}
+
+func newOptions(opts []Option) options {
+	ret := options{maxBodySize: DefaultMaxBodySize, streamHeartbeat: DefaultStreamHeartbeat}
+	for _, o := range opts {
+		o(&ret)
+	}
+	return ret
+}
+
+// WithMaxBodySize limits the size of the request body; larger requests are rejected with 413.
+// Zero or negative size disables the limit.
+func WithMaxBodySize(size int64) Option {
+	return func(o *options) {
+		o.maxBodySize = size
+	}
+}
+
+// WithDisallowUnknownFields rejects the request bodies with the fields unknown to the input type.
+//
+// The check is done for the form bodies and the formats implementing negmarshal.StrictCodec.
+func WithDisallowUnknownFields(disallow bool) Option {
+	return func(o *options) {
+		o.disallowUnknownFields = disallow
+	}
+}
+
+// WithStreamHeartbeat sets the interval of the SSE streams' heartbeats; zero disables them.
+// NDJSON has no room for them, since its every line is a value.
+func WithStreamHeartbeat(interval time.Duration) Option {
+	return func(o *options) {
+		o.streamHeartbeat = interval
+	}
+}
+
+// WithWebSocket configures the upgrade of the WebSocket handlers' connections.
+func WithWebSocket(wo ws.Options) Option {
+	return func(o *options) {
+		o.webSocket = wo
+	}
+}
+
+// WithUploadLimits sets the limits of the multipart uploads; see File.
+// The input types implementing UploadLimiter override them for their routes.
+func WithUploadLimits(l UploadLimits) Option {
+	return func(o *options) {
+		o.uploads = l
+	}
+}
@@ -0,0 +1,184 @@
+package httpbinding
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+)
+
+// StatusCoder is implemented by the output types which choose the response's status code.
+//
+// The status code of the type's zero value is documented as the successful response's one.
+// Zero means 200 OK.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// StatusDocumenter is implemented by the output types responding with several status codes,
+// so that all of them are documented.
+type StatusDocumenter interface {
+	ResponseStatuses() []int
+}
+
+// Headerer is implemented by the output types which set the response headers.
+type Headerer interface {
+	Headers() http.Header
+}
+
+// Cookier is implemented by the output types which set the response cookies.
+type Cookier interface {
+	Cookies() []*http.Cookie
+}
+
+// Response wraps the handler's output with the response's status code, headers and cookies.
+//
+// Only the Body is marshaled and documented. The documented status codes are declared by T
+// implementing StatusDocumenter or StatusCoder; 200 OK is documented otherwise.
+type Response[T any] struct {
+	Body T
+	// Status is the response's status code; zero means the Body's StatusCoder code, or 200 OK.
+	// 204 and 304 responses are written without the body.
+	Status     int
+	Header     http.Header
+	SetCookies []*http.Cookie
+}
+
+// ResponseReader is implemented by *Response, so that the clients read the received responses into it.
+type ResponseReader interface {
+	// ReadResponse sets the response's status code and headers.
+	ReadResponse(status int, header http.Header)
+	// ResponseBody returns the pointer to decode the response's body to.
+	ResponseBody() any
+}
+
+var (
+	_ StatusCoder    = Response[any]{}
+	_ Headerer       = Response[any]{}
+	_ Cookier        = Response[any]{}
+	_ ResponseReader = (*Response[any])(nil)
+)
+
+func (r Response[T]) StatusCode() int {
+	if r.Status == 0 {
+		if s, ok := any(r.Body).(StatusCoder); ok {
+			return s.StatusCode()
+		}
+	}
+	return r.Status
+}
+
+func (r Response[T]) Headers() http.Header    { return r.Header }
+func (r Response[T]) Cookies() []*http.Cookie { return r.SetCookies }
+
+func (r *Response[T]) ReadResponse(status int, header http.Header) {
+	r.Status, r.Header = status, header
+}
+
+func (r *Response[T]) ResponseBody() any { return &r.Body }
+
+func (r Response[T]) responseBody() any { return r.Body }
+
+func (r Response[T]) bodyType() reflect.Type { return reflect.TypeFor[T]() }
+
+// bodyWrapper is implemented by Response.
+type bodyWrapper interface {
+	responseBody() any
+	bodyType() reflect.Type
+}
+
+// outputMeta returns the documented body type and status codes of the handler's output type.
+func outputMeta(t reflect.Type) (body reflect.Type, statuses []int) {
+	v := zeroOf(t)
+	if w, ok := v.(bodyWrapper); ok {
+		// the wrapper's status is set per response, its body type declares the documented ones
+		body = w.bodyType()
+		return body, statusesOf(zeroOf(body))
+	}
+	return t, statusesOf(v)
+}
+
+// zeroOf returns the zero value of the type; the pointers point to the zero values.
+func zeroOf(t reflect.Type) any {
+	if t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface()
+	}
+	return reflect.Zero(t).Interface()
+}
+
+// statusesOf returns the status codes declared by the value.
+func statusesOf(v any) []int {
+	switch v := v.(type) {
+	case StatusDocumenter:
+		return v.ResponseStatuses()
+	case StatusCoder:
+		if code := v.StatusCode(); code != 0 {
+			return []int{code}
+		}
+	}
+	return nil
+}
+
+// writeOutput marshals the handler's output, honoring its status code, headers and cookies.
+func writeOutput(r *http.Request, w http.ResponseWriter, marshaler negmarshal.NegotiatedMarshalFunc, v any) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return marshaler(r, w, v, nil)
+	}
+
+	if h, ok := v.(Headerer); ok {
+		for k, vals := range h.Headers() {
+			for _, val := range vals {
+				w.Header().Add(k, val)
+			}
+		}
+	}
+	if c, ok := v.(Cookier); ok {
+		for _, cookie := range c.Cookies() {
+			http.SetCookie(w, cookie)
+		}
+	}
+	status := http.StatusOK
+	if s, ok := v.(StatusCoder); ok && s.StatusCode() != 0 {
+		status = s.StatusCode()
+	}
+	if b, ok := v.(bodyWrapper); ok {
+		v = b.responseBody()
+	}
+
+	switch {
+	case status == http.StatusNoContent || status == http.StatusNotModified:
+		w.WriteHeader(status)
+		return nil
+	case status != http.StatusOK:
+		w = &statusWriter{ResponseWriter: w, status: status}
+	}
+	return marshaler(r, w, v, nil)
+}
+
+// statusWriter replaces the implicit 200 OK with the output's status code.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.wroteHeader = true
+		if code == http.StatusOK {
+			code = s.status
+		}
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK)
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
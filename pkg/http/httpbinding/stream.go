@@ -0,0 +1,225 @@
+package httpbinding
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/pkg/http/errmarshalhttp"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/observe/errobserve"
+)
+
+// DefaultStreamHeartbeat is the default interval of the streams' heartbeats.
+const DefaultStreamHeartbeat = 15 * time.Second
+
+// ContentTypeSSE is the media type of the Server-Sent Events streams;
+// the NDJSON ones are negmarshal.NDJSON()'s.
+const ContentTypeSSE = "text/event-stream"
+
+// newTicker starts the heartbeats' ticker; it's replaced in tests.
+var newTicker = func(d time.Duration) (tick <-chan time.Time, stop func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// streamItem is a single value or error of a stream.
+type streamItem struct {
+	v   any
+	err error
+}
+
+// streamOutput describes the handler's streaming output type:
+// either iter.Seq2[T, error] or a receive channel of T.
+type streamOutput struct {
+	itemType reflect.Type
+	isChan   bool
+}
+
+var errorType = reflect.TypeFor[error]()
+
+// streamOutputOf returns the stream description of the output type, if it's a stream.
+func streamOutputOf(t reflect.Type) (streamOutput, bool) {
+	switch t.Kind() {
+	case reflect.Chan:
+		if t.ChanDir()&reflect.RecvDir == 0 {
+			return streamOutput{}, false
+		}
+		return streamOutput{itemType: t.Elem(), isChan: true}, true
+	case reflect.Func:
+		// iter.Seq2[T, error] is func(yield func(T, error) bool)
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			return streamOutput{}, false
+		}
+		yield := t.In(0)
+		if yield.Kind() != reflect.Func || yield.NumIn() != 2 || yield.NumOut() != 1 ||
+			yield.In(1) != errorType || yield.Out(0).Kind() != reflect.Bool {
+			return streamOutput{}, false
+		}
+		return streamOutput{itemType: yield.In(0)}, true
+	}
+	return streamOutput{}, false
+}
+
+// produce pushes the stream's values to the returned channel until the stream ends or ctx is done.
+// After that, the channel streams are drained until closed, so that their senders never block.
+func (s streamOutput) produce(ctx context.Context, stream reflect.Value) <-chan streamItem {
+	ret := make(chan streamItem)
+	send := func(it streamItem) bool {
+		select {
+		case ret <- it:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	go func() {
+		defer close(ret)
+		if stream.IsNil() {
+			return
+		}
+
+		if s.isChan {
+			defer func() {
+				for ok := true; ok; _, ok = stream.Recv() {
+				}
+			}()
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: stream},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			}
+			for {
+				chosen, v, ok := reflect.Select(cases)
+				if chosen == 1 || !ok {
+					return
+				}
+				if !send(streamItem{v: v.Interface()}) {
+					return
+				}
+			}
+		}
+
+		yield := reflect.MakeFunc(stream.Type().In(0), func(args []reflect.Value) []reflect.Value {
+			it := streamItem{v: args[0].Interface()}
+			if !args[1].IsNil() {
+				it.err = args[1].Interface().(error)
+			}
+			// stop the iteration after the error, too
+			return []reflect.Value{reflect.ValueOf(send(it) && it.err == nil)}
+		})
+		stream.Call([]reflect.Value{yield})
+	}()
+	return ret
+}
+
+// writeStream sends the stream as SSE or NDJSON, depending on the request's Accept header.
+//
+// Every value is flushed as soon as it's written; the SSE comments keep the idle connections alive.
+// The stream's error is written as the last event: a problem document line for NDJSON,
+// or the `error` event for SSE.
+//
+// Once it returns, including the 406 response and the client's disconnect, the stream's producer
+// is stopped, and the request's context is canceled by the caller.
+func writeStream(r *http.Request, w http.ResponseWriter, marshaler negmarshal.NegotiatedMarshalFunc, s streamOutput, stream reflect.Value, heartbeat time.Duration) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	items := s.produce(ctx, stream)
+
+	ndjson := negmarshal.NDJSON().ContentType()
+	contentType, ok := negmarshal.Negotiate(r.Header.Get("Accept"), ndjson, ContentTypeSSE)
+	if !ok {
+		writeError(w, r, marshaler, negmarshal.ErrNotAcceptable.Wrap(errors.Errorf("streams are sent as %v or %v only", ndjson, ContentTypeSSE)))
+		return
+	}
+	enc := streamEncoder{w: w, sse: contentType == ContentTypeSSE}
+
+	rc := http.NewResponseController(w)
+	// streams outlive the server's write timeout
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	var tick <-chan time.Time
+	if heartbeat > 0 && enc.sse {
+		var stop func()
+		tick, stop = newTicker(heartbeat)
+		defer stop()
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			err = enc.heartbeat()
+		case it, ok := <-items:
+			switch {
+			case !ok:
+				return
+			case it.err != nil:
+				log.Errorv(ctx, "stream failed", it.err)
+				errobserve.Record(ctx, it.err)
+				err = enc.problem(errmarshalhttp.ToRFC7807(ctx, it.err))
+			default:
+				err = enc.value(it.v)
+			}
+		}
+		if err != nil {
+			// the client is gone
+			log.Debug(ctx, "failed to write the stream", "error", err.Error())
+			return
+		}
+		_ = rc.Flush()
+	}
+}
+
+type streamEncoder struct {
+	w   io.Writer
+	sse bool
+}
+
+func (e streamEncoder) value(v any) error {
+	return e.event("", v)
+}
+
+func (e streamEncoder) problem(v any) error {
+	return e.event("error", v)
+}
+
+func (e streamEncoder) event(name string, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "when marshaling stream value")
+	}
+	if !e.sse {
+		_, err = e.w.Write(append(buf, '\n'))
+		return err
+	}
+
+	var ev []byte
+	if name != "" {
+		ev = append(ev, "event: "+name+"\n"...)
+	}
+	ev = append(ev, "data: "...)
+	ev = append(ev, buf...)
+	ev = append(ev, "\n\n"...)
+	_, err = e.w.Write(ev)
+	return err
+}
+
+// heartbeat writes the SSE comment, which the clients ignore.
+func (e streamEncoder) heartbeat() error {
+	_, err := io.WriteString(e.w, ": heartbeat\n\n")
+	return err
+}
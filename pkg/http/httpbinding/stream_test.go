@@ -0,0 +1,187 @@
+package httpbinding
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+)
+
+type streamed struct {
+	N int `json:"n"`
+}
+
+func TestBindHTTPHandler_stream(t *testing.T) {
+	errGone := errors.NewCoder("GONE").WithHTTPCode(410).WithMessage("gone")
+
+	seq := func(context.Context) (iter.Seq2[streamed, error], error) {
+		return func(yield func(streamed, error) bool) {
+			for i := 1; i <= 2; i++ {
+				if !yield(streamed{N: i}, nil) {
+					return
+				}
+			}
+			yield(streamed{}, errGone.Wrap(errors.New("no more")))
+		}, nil
+	}
+
+	tests := []struct {
+		name     string
+		accept   string
+		wantCode int
+		wantType string
+		wantBody string
+	}{
+		{"ndjson", "", 200, "application/x-ndjson", "{\"n\":1}\n{\"n\":2}\n{\"type\":\"GONE\""},
+		{"sse", "text/event-stream", 200, ContentTypeSSE, "data: {\"n\":1}\n\ndata: {\"n\":2}\n\nevent: error\ndata: {\"type\":\"GONE\""},
+		{"not acceptable", "text/csv", 406, "application/json", "NOT_ACCEPTABLE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			so := require.New(t)
+			hdl, meta, err := BindHTTPHandlerMeta(seq, negmarshal.Default())
+			so.NoError(err)
+			so.True(meta.Streaming)
+			so.Equal("streamed", meta.OutputType.Name())
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rsp := httptest.NewRecorder()
+			hdl.ServeHTTP(rsp, req)
+
+			so.Equal(tt.wantCode, rsp.Code)
+			so.Equal(tt.wantType, rsp.Header().Get("Content-Type"))
+			so.Contains(rsp.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestBindHTTPHandler_streamChan(t *testing.T) {
+	ticks := make(chan time.Time)
+	var tickers int
+	newTicker = func(time.Duration) (<-chan time.Time, func()) {
+		tickers++
+		return ticks, func() {}
+	}
+	t.Cleanup(func() {
+		newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+			t := time.NewTicker(d)
+			return t.C, t.Stop
+		}
+	})
+
+	// between is called after the first value is sent
+	handler := func(between func()) http.Handler {
+		hdl, err := BindHTTPHandler(func(context.Context) (<-chan streamed, error) {
+			ch := make(chan streamed)
+			go func() {
+				defer close(ch)
+				ch <- streamed{N: 1}
+				between()
+				ch <- streamed{N: 2}
+			}()
+			return ch, nil
+		}, negmarshal.Default(), WithStreamHeartbeat(time.Hour))
+		require.NoError(t, err)
+		return hdl
+	}
+
+	t.Run("sse", func(t *testing.T) {
+		so := require.New(t)
+		tickers = 0
+		// the stream doesn't end until the heartbeat is due
+		hdl := handler(func() { ticks <- time.Time{} })
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		rsp := httptest.NewRecorder()
+		hdl.ServeHTTP(rsp, req)
+
+		so.Equal(1, tickers)
+		so.Equal(1, strings.Count(rsp.Body.String(), ": heartbeat\n\n"))
+		so.Contains(rsp.Body.String(), "data: {\"n\":1}\n\n")
+		so.True(strings.HasSuffix(rsp.Body.String(), "data: {\"n\":2}\n\n"))
+	})
+	t.Run("ndjson", func(t *testing.T) {
+		so := require.New(t)
+		tickers = 0
+		hdl := handler(func() {})
+
+		rsp := httptest.NewRecorder()
+		hdl.ServeHTTP(rsp, httptest.NewRequest("GET", "/", nil))
+
+		so.Zero(tickers)
+		so.Equal("{\"n\":1}\n{\"n\":2}\n", rsp.Body.String())
+	})
+}
+
+func TestBindHTTPHandler_streamNotAcceptable(t *testing.T) {
+	so := require.New(t)
+
+	var hctx context.Context
+	drained := make(chan struct{})
+	hdl, err := BindHTTPHandler(func(ctx context.Context) (<-chan streamed, error) {
+		hctx = ctx
+		ch := make(chan streamed)
+		go func() {
+			// the sender ignores the context; it still mustn't block forever
+			defer close(drained)
+			defer close(ch)
+			for i := 0; i < 3; i++ {
+				ch <- streamed{N: i}
+			}
+		}()
+		return ch, nil
+	}, negmarshal.Default())
+	so.NoError(err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/csv")
+	rsp := httptest.NewRecorder()
+	hdl.ServeHTTP(rsp, req)
+	so.Equal(406, rsp.Code)
+	so.ErrorIs(hctx.Err(), context.Canceled)
+
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		so.Fail("the channel wasn't drained")
+	}
+}
+
+func TestBindHTTPHandler_streamCancel(t *testing.T) {
+	so := require.New(t)
+
+	stopped := make(chan struct{})
+	hdl, err := BindHTTPHandler(func(context.Context) (iter.Seq2[streamed, error], error) {
+		return func(yield func(streamed, error) bool) {
+			defer close(stopped)
+			for i := 0; ; i++ {
+				if !yield(streamed{N: i}, nil) {
+					return
+				}
+			}
+		}, nil
+	}, negmarshal.Default())
+	so.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		so.Fail("the iterator wasn't stopped after the client has gone")
+	}
+}
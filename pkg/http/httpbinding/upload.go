@@ -0,0 +1,215 @@
+package httpbinding
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"reflect"
+
+	"github.com/utrack/caisson-go/errors"
+)
+
+// DefaultSpillThreshold is the default size of an uploaded file kept in memory.
+const DefaultSpillThreshold = 1 << 20
+
+var (
+	typeFile  = reflect.TypeOf((*File)(nil))
+	typeFiles = reflect.TypeOf([]*File(nil))
+)
+
+// File is a file uploaded via the multipart/form-data request.
+//
+// Declare the input's untagged fields as *File or []*File to receive the files
+// of the form field named after the field's json tag.
+// The parts are streamed from the request body: the files up to UploadLimits.SpillThreshold are kept in memory,
+// the larger ones are written to the temp files which are removed when the request ends.
+//
+// File implements httpin's core.FileHeader.
+type File struct {
+	filename string
+	header   textproto.MIMEHeader
+	size     int64
+
+	// buf is the content of the in-memory file
+	buf []byte
+	// path is the temp file's path of the spilled file
+	path string
+}
+
+// Filename is the file's name as sent by the client.
+func (f *File) Filename() string {
+	return f.filename
+}
+
+// Size is the file's size in bytes.
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// ContentType is the part's Content-Type as sent by the client.
+func (f *File) ContentType() string {
+	return f.header.Get("Content-Type")
+}
+
+// MIMEHeader returns the part's headers.
+func (f *File) MIMEHeader() textproto.MIMEHeader {
+	return f.header
+}
+
+// Open opens the file's content for reading; every call returns an independent reader.
+func (f *File) Open() (multipart.File, error) {
+	if f.path != "" {
+		return os.Open(f.path)
+	}
+	return memFile{io.NewSectionReader(bytes.NewReader(f.buf), 0, f.size)}, nil
+}
+
+type memFile struct {
+	*io.SectionReader
+}
+
+func (memFile) Close() error {
+	return nil
+}
+
+// UploadLimits restrict the multipart/form-data requests decoded into File fields.
+// Zero fields are inherited from the binding's limits.
+type UploadLimits struct {
+	// MaxBodySize overrides WithMaxBodySize for the route.
+	MaxBodySize int64
+	// MaxFileSize limits the size of each uploaded file; larger files are rejected with 413.
+	// Zero means the files are limited by the body size only.
+	MaxFileSize int64
+	// SpillThreshold is the size of a file kept in memory; larger files are written to the temp files.
+	// The default is DefaultSpillThreshold.
+	SpillThreshold int64
+}
+
+// UploadLimiter is implemented by the input types setting their routes' upload limits.
+type UploadLimiter interface {
+	UploadLimits() UploadLimits
+}
+
+// merge overrides the limits with the non-zero ones.
+func (l UploadLimits) merge(o UploadLimits) UploadLimits {
+	if o.MaxBodySize != 0 {
+		l.MaxBodySize = o.MaxBodySize
+	}
+	if o.MaxFileSize != 0 {
+		l.MaxFileSize = o.MaxFileSize
+	}
+	if o.SpillThreshold != 0 {
+		l.SpillThreshold = o.SpillThreshold
+	}
+	return l
+}
+
+// readMultipart streams the multipart/form-data body into the form values and files.
+func readMultipart(r *http.Request, lim UploadLimits) (form, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return form{}, err
+	}
+
+	ret := form{values: map[string][]string{}, files: map[string][]*File{}}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return ret, nil
+		}
+		if err != nil {
+			return form{}, err
+		}
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+
+		if p.FileName() == "" {
+			buf, err := io.ReadAll(p)
+			if err != nil {
+				return form{}, err
+			}
+			ret.values[name] = append(ret.values[name], string(buf))
+			continue
+		}
+
+		f, err := readFile(r.Context(), p, lim)
+		if err != nil {
+			return form{}, errors.Wrapf(err, "when reading file '%v'", name)
+		}
+		ret.files[name] = append(ret.files[name], f)
+	}
+}
+
+// readFile reads the file's part, spilling it to the temp file if it exceeds the threshold.
+// The temp file is removed once ctx is done.
+func readFile(ctx context.Context, p *multipart.Part, lim UploadLimits) (*File, error) {
+	f := &File{filename: p.FileName(), header: p.Header}
+
+	src := io.Reader(p)
+	if lim.MaxFileSize > 0 {
+		// read one byte over the limit to detect the larger files
+		src = io.LimitReader(p, lim.MaxFileSize+1)
+	}
+	threshold := lim.SpillThreshold
+	if threshold <= 0 {
+		threshold = DefaultSpillThreshold
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, src, threshold+1)
+	switch {
+	case err == io.EOF:
+		f.buf, f.size = buf.Bytes(), n
+	case err != nil:
+		return nil, err
+	default:
+		tmp, err := os.CreateTemp("", "caisson-upload-*")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create temp file")
+		}
+		context.AfterFunc(ctx, func() {
+			_ = os.Remove(tmp.Name())
+		})
+		f.path = tmp.Name()
+
+		f.size, err = io.Copy(tmp, io.MultiReader(&buf, src))
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if lim.MaxFileSize > 0 && f.size > lim.MaxFileSize {
+		return nil, ErrRequestTooLarge.Wrap(errors.Errorf("file '%v' is larger than %d bytes", f.filename, lim.MaxFileSize))
+	}
+	return f, nil
+}
+
+// hasFileHeaders reports whether the struct decodes the files into *multipart.FileHeader fields;
+// those are filled by http.Request.ParseMultipartForm instead of the streaming reader.
+func hasFileHeaders(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		switch {
+		case f.Type == typeFileHeader || f.Type == typeFileHeaders:
+			return true
+		case f.Anonymous && hasFileHeaders(f.Type):
+			return true
+		}
+	}
+	return false
+}
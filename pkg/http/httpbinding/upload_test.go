@@ -0,0 +1,101 @@
+package httpbinding
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+)
+
+type uploadInput struct {
+	Title       string  `json:"title"`
+	Avatar      *File   `json:"avatar"`
+	Attachments []*File `json:"attachments"`
+}
+
+func (uploadInput) UploadLimits() UploadLimits {
+	return UploadLimits{MaxFileSize: 64, SpillThreshold: 8}
+}
+
+func uploadBody(files map[string]string) (string, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("title", "hello")
+	for name, content := range files {
+		field, _, _ := strings.Cut(name, "/")
+		w, _ := mw.CreateFormFile(field, name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = mw.Close()
+	return buf.String(), mw.FormDataContentType()
+}
+
+func TestBindHTTPHandler_upload(t *testing.T) {
+	t.Run("memory and spill", func(t *testing.T) {
+		so := require.New(t)
+
+		var got uploadInput
+		var spilled string
+		hdl, err := BindHTTPHandler(func(_ context.Context, in uploadInput) error {
+			got = in
+			spilled = in.Attachments[0].path
+			_, err := os.Stat(spilled)
+			return err
+		}, negmarshal.Default())
+		so.NoError(err)
+
+		body, ct := uploadBody(map[string]string{"avatar/a.png": "small", "attachments/b.txt": "larger than eight"})
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", ct)
+		rsp := httptest.NewRecorder()
+		hdl.ServeHTTP(rsp, req)
+		so.Equal(200, rsp.Code, rsp.Body.String())
+
+		so.Equal("hello", got.Title)
+		so.Equal("a.png", got.Avatar.Filename())
+		so.Equal("application/octet-stream", got.Avatar.ContentType())
+		so.EqualValues(5, got.Avatar.Size())
+		so.Empty(got.Avatar.path)
+
+		so.Len(got.Attachments, 1)
+		so.NotEmpty(spilled)
+		f, err := got.Attachments[0].Open()
+		so.NoError(err)
+		content, err := io.ReadAll(f)
+		so.NoError(err)
+		so.NoError(f.Close())
+		so.Equal("larger than eight", string(content))
+
+		// the request is over
+		cancel()
+		so.Eventually(func() bool {
+			_, err := os.Stat(spilled)
+			return os.IsNotExist(err)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("file too large", func(t *testing.T) {
+		so := require.New(t)
+
+		hdl, err := BindHTTPHandler(func(context.Context, uploadInput) error {
+			return nil
+		}, negmarshal.Default())
+		so.NoError(err)
+
+		body, ct := uploadBody(map[string]string{"avatar/a.png": strings.Repeat("x", 65)})
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", ct)
+		rsp := httptest.NewRecorder()
+		hdl.ServeHTTP(rsp, req)
+		so.Equal(413, rsp.Code)
+	})
+}
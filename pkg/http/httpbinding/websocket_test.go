@@ -0,0 +1,132 @@
+package httpbinding
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/http/ws"
+)
+
+type wsInput struct {
+	Prefix string `in:"query=prefix"`
+}
+
+type wsReply struct {
+	Text string `json:"text"`
+}
+
+func TestBindHTTPHandler_webSocket(t *testing.T) {
+	errStop := errors.NewCoder("STOPPED").WithHTTPCode(409).WithMessage("stopped by client")
+
+	echo := func(ctx context.Context, in wsInput, conn *ws.Conn[streamed, wsReply]) error {
+		for {
+			msg, err := conn.Receive(ctx)
+			if err != nil {
+				return err
+			}
+			if msg.N < 0 {
+				return errStop.Wrap(errors.New("negative"))
+			}
+			if err := conn.Send(ctx, wsReply{Text: in.Prefix + strings.Repeat("!", msg.N)}); err != nil {
+				return err
+			}
+		}
+	}
+
+	reg := ws.NewRegistry()
+	hdl, meta, err := BindHTTPHandlerMeta(echo, negmarshal.Default(), WithWebSocket(ws.Options{Registry: reg}))
+	require.NoError(t, err)
+	srv := httptest.NewServer(hdl)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?prefix=hi"
+
+	t.Run("meta", func(t *testing.T) {
+		so := require.New(t)
+		so.True(meta.WebSocket)
+		so.Equal([]int{101}, meta.StatusCodes)
+		so.Equal("streamed", meta.ReceiveType.Name())
+		so.Equal("wsReply", meta.OutputType.Name())
+	})
+
+	t.Run("echo and close", func(t *testing.T) {
+		so := require.New(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		c, _, err := websocket.Dial(ctx, url, nil)
+		so.NoError(err)
+		defer c.CloseNow()
+
+		so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":2}`)))
+		typ, buf, err := c.Read(ctx)
+		so.NoError(err)
+		so.Equal(websocket.MessageText, typ)
+		so.JSONEq(`{"text":"hi!!"}`, string(buf))
+
+		so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":-1}`)))
+		_, _, err = c.Read(ctx)
+		so.Equal(websocket.StatusCode(4409), websocket.CloseStatus(err))
+	})
+
+	t.Run("msgpack subprotocol", func(t *testing.T) {
+		so := require.New(t)
+		negmarshal.Register(negmarshal.MsgPack())
+		t.Cleanup(func() { negmarshal.Unregister(negmarshal.MsgPack().ContentType()) })
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		c, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{"msgpack"}})
+		so.NoError(err)
+		defer c.CloseNow()
+		so.Equal("msgpack", c.Subprotocol())
+
+		var req strings.Builder
+		so.NoError(negmarshal.MsgPack().Encode(&req, streamed{N: 1}))
+		so.NoError(c.Write(ctx, websocket.MessageBinary, []byte(req.String())))
+
+		typ, buf, err := c.Read(ctx)
+		so.NoError(err)
+		so.Equal(websocket.MessageBinary, typ)
+		var rsp wsReply
+		so.NoError(negmarshal.MsgPack().Decode(strings.NewReader(string(buf)), &rsp))
+		so.Equal("hi!", rsp.Text)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		so := require.New(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		c, _, err := websocket.Dial(ctx, url, nil)
+		so.NoError(err)
+		defer c.CloseNow()
+
+		// the connection is registered before the handler runs, so it is by the time the reply comes
+		so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":1}`)))
+		_, _, err = c.Read(ctx)
+		so.NoError(err)
+
+		// read concurrently to answer the close frame
+		readErr := make(chan error, 1)
+		go func() {
+			_, _, err := c.Read(ctx)
+			readErr <- err
+		}()
+
+		so.NoError(reg.Shutdown(ctx))
+		so.Equal(websocket.StatusGoingAway, websocket.CloseStatus(<-readErr))
+		so.Zero(reg.Len())
+
+		_, rsp, err := websocket.Dial(ctx, url, nil)
+		so.Error(err)
+		so.Equal(503, rsp.StatusCode)
+		so.Equal("application/json", rsp.Header.Get("Content-Type"))
+	})
+}
@@ -0,0 +1,384 @@
+package negmarshal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnsupportedMediaType is returned when the request's body is in a format without a registered Codec.
+var ErrUnsupportedMediaType = errors.Register(errors.NewCoder("UNSUPPORTED_MEDIA_TYPE").WithHTTPCode(http.StatusUnsupportedMediaType).WithMessage("request content type is not supported"))
+
+// Codec encodes and decodes the values in a single wire format.
+//
+// The same codecs are used to marshal the responses (negotiated via Accept)
+// and to decode the requests' bodies (selected via Content-Type).
+type Codec interface {
+	// ContentType is the media type of the format, like application/json.
+	ContentType() string
+	// ProblemContentType is the media type of the RFC7807 problems in this format, like application/problem+json.
+	// It is used for the raw-style error responses.
+	ProblemContentType() string
+
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+// ValueOnlyCodec is a Codec which can't encode arbitrary values, like protobuf.
+//
+// Its responses are never wrapped in the envelope, and the errors are written as application/problem+json.
+// The negotiator skips it for the values it can't encode, falling back to the next accepted format.
+type ValueOnlyCodec interface {
+	Codec
+	CanEncode(v any) bool
+}
+
+// StrictCodec is a Codec which is able to reject the unknown fields while decoding.
+type StrictCodec interface {
+	Codec
+	DecodeStrict(r io.Reader, v any) error
+}
+
+var registry = struct {
+	sync.RWMutex
+	codecs []Codec
+}{codecs: []Codec{JSON(), XML()}}
+
+// Register adds the codecs to the formats supported by the Default() and ForStyle() negotiators
+// and by Decode().
+// A codec replaces the registered one with the same content type.
+//
+// JSON and XML are registered by default. Register the rest during the app's initialization,
+// before the handlers are bound - the negotiators are built with the codecs registered at the time.
+func Register(codecs ...Codec) {
+	registry.Lock()
+	defer registry.Unlock()
+
+codecs:
+	for _, c := range codecs {
+		for i, known := range registry.codecs {
+			if known.ContentType() == c.ContentType() {
+				registry.codecs[i] = c
+				continue codecs
+			}
+		}
+		registry.codecs = append(registry.codecs, c)
+	}
+}
+
+// Unregister removes the codecs of the content types, like the ones added in tests.
+// The first registered codec (JSON) is kept, since it's the default one.
+func Unregister(contentTypes ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	kept := registry.codecs[:1]
+	for _, c := range registry.codecs[1:] {
+		if !slices.Contains(contentTypes, c.ContentType()) {
+			kept = append(kept, c)
+		}
+	}
+	registry.codecs = kept
+}
+
+// Codecs returns the registered codecs in the order of preference.
+func Codecs() []Codec {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]Codec(nil), registry.codecs...)
+}
+
+// CodecFor returns the registered codec for the media type, like the Content-Type header's value.
+func CodecFor(contentType string) (Codec, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range Codecs() {
+		if c.ContentType() == mt {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Decode decodes the request's body to v using the codec selected by the Content-Type header.
+// A request without the Content-Type is decoded as JSON.
+func Decode(r *http.Request, v any) error {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		ct = "application/json"
+	}
+	c, ok := CodecFor(ct)
+	if !ok {
+		return ErrUnsupportedMediaType.Wrap(errors.Errorf("no codec registered for content type '%v'", ct))
+	}
+	if err := c.Decode(r.Body, v); err != nil {
+		return errors.Wrapf(err, "when decoding %v request body", c.ContentType())
+	}
+	return nil
+}
+
+// JSON returns the application/json codec.
+func JSON() Codec {
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string        { return "application/json" }
+func (jsonCodec) ProblemContentType() string { return "application/problem+json" }
+
+func (jsonCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (jsonCodec) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func (jsonCodec) DecodeStrict(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// XML returns the application/xml codec.
+func XML() Codec {
+	return xmlCodec{}
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string        { return "application/xml" }
+func (xmlCodec) ProblemContentType() string { return "application/problem+xml" }
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (xmlCodec) Decode(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// NDJSON returns the application/x-ndjson codec.
+//
+// A single value is written as one JSON line; the request body is decoded from its first line.
+func NDJSON() Codec {
+	return ndjsonCodec{}
+}
+
+type ndjsonCodec struct{}
+
+func (ndjsonCodec) ContentType() string        { return "application/x-ndjson" }
+func (ndjsonCodec) ProblemContentType() string { return "application/problem+json" }
+
+func (ndjsonCodec) Encode(w io.Writer, v any) error {
+	// json.Encoder terminates every value with a newline
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (ndjsonCodec) Decode(r io.Reader, v any) error {
+	return decodeLine(r, v, false)
+}
+
+func (ndjsonCodec) DecodeStrict(r io.Reader, v any) error {
+	return decodeLine(r, v, true)
+}
+
+func decodeLine(r io.Reader, v any, strict bool) error {
+	line, err := bufio.NewReader(r).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return decodeJSON(line, v, strict)
+}
+
+func decodeJSON(buf []byte, v any, strict bool) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// YAML returns the application/yaml codec.
+//
+// The values are converted via their JSON representation, so the json struct tags apply.
+func YAML() Codec {
+	return yamlCodec{}
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string        { return "application/yaml" }
+func (yamlCodec) ProblemContentType() string { return "application/problem+yaml" }
+
+func (yamlCodec) Encode(w io.Writer, v any) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// yaml.Node keeps the keys' order of the JSON document
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle resets the JSON's flow style and quoting to the YAML defaults.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+func (yamlCodec) Decode(r io.Reader, v any) error {
+	return decodeYAML(r, v, false)
+}
+
+func (yamlCodec) DecodeStrict(r io.Reader, v any) error {
+	return decodeYAML(r, v, true)
+}
+
+func decodeYAML(r io.Reader, v any, strict bool) error {
+	var doc any
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return decodeJSON(buf, v, strict)
+}
+
+// MsgPack returns the application/msgpack codec.
+//
+// The json struct tags are used for the field names.
+func MsgPack() Codec {
+	return msgpackCodec{}
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string        { return "application/msgpack" }
+func (msgpackCodec) ProblemContentType() string { return "application/problem+msgpack" }
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func (msgpackCodec) Decode(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (msgpackCodec) DecodeStrict(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(true)
+	return dec.Decode(v)
+}
+
+// CBOR returns the application/cbor codec.
+//
+// The cbor struct tags are used for the field names, falling back to the json ones.
+func CBOR() Codec {
+	return cborCodec{}
+}
+
+type cborCodec struct{}
+
+func (cborCodec) ContentType() string        { return "application/cbor" }
+func (cborCodec) ProblemContentType() string { return "application/problem+cbor" }
+
+func (cborCodec) Encode(w io.Writer, v any) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
+
+func (cborCodec) Decode(r io.Reader, v any) error {
+	return cbor.NewDecoder(r).Decode(v)
+}
+
+var cborStrict = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+func (cborCodec) DecodeStrict(r io.Reader, v any) error {
+	return cborStrict.NewDecoder(r).Decode(v)
+}
+
+// Protobuf returns the application/x-protobuf codec.
+//
+// It encodes and decodes proto.Message values only; see ValueOnlyCodec.
+func Protobuf() ValueOnlyCodec {
+	return protobufCodec{}
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string        { return "application/x-protobuf" }
+func (protobufCodec) ProblemContentType() string { return "application/problem+json" }
+
+func (protobufCodec) CanEncode(v any) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+func (protobufCodec) Encode(w io.Writer, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	buf, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func (protobufCodec) Decode(r io.Reader, v any) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.Errorf("protobuf: %T is not a proto.Message", v)
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(buf, msg)
+}
+
+// canEncode reports whether the codec is able to encode the value.
+func canEncode(c Codec, v any) bool {
+	vo, ok := c.(ValueOnlyCodec)
+	return !ok || vo.CanEncode(v)
+}
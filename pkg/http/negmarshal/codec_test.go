@@ -0,0 +1,101 @@
+package negmarshal
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCodecs_roundtrip(t *testing.T) {
+	type item struct {
+		UserID string `json:"user_id"`
+		Count  int    `json:"count"`
+	}
+
+	for _, c := range []Codec{JSON(), XML(), NDJSON(), YAML(), MsgPack(), CBOR()} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			so := require.New(t)
+			var buf bytes.Buffer
+			so.NoError(c.Encode(&buf, item{UserID: "u1", Count: 2}))
+
+			var got item
+			so.NoError(c.Decode(&buf, &got))
+			so.Equal(item{UserID: "u1", Count: 2}, got)
+		})
+	}
+
+	t.Run("protobuf", func(t *testing.T) {
+		so := require.New(t)
+		c := Protobuf()
+		so.False(c.CanEncode(struct{}{}))
+
+		var buf bytes.Buffer
+		so.NoError(c.Encode(&buf, wrapperspb.String("hello")))
+		got := &wrapperspb.StringValue{}
+		so.NoError(c.Decode(&buf, got))
+		so.True(proto.Equal(wrapperspb.String("hello"), got))
+	})
+}
+
+func TestYAML_jsonTags(t *testing.T) {
+	so := require.New(t)
+	var buf bytes.Buffer
+	so.NoError(YAML().Encode(&buf, struct {
+		UserID string `json:"user_id"`
+	}{"u1"}))
+	so.Equal("user_id: u1\n", buf.String())
+}
+
+func TestNegotiator_formats(t *testing.T) {
+	marshal := forCodecs(StyleRaw, []Codec{JSON(), XML(), YAML(), MsgPack(), Protobuf()})
+
+	type out struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	tests := []struct {
+		name     string
+		accept   string
+		v        any
+		wantType string
+	}{
+		{"q-values", "application/json;q=0.5, application/yaml", struct{}{}, "application/yaml"},
+		{"specific over wildcard", "*/*, application/msgpack", struct{}{}, "application/msgpack"},
+		{"wildcard subtype picks first registered", "application/*", struct{}{}, "application/json"},
+		{"rejected via q=0", "application/json;q=0, application/*;q=0.5", out{"a"}, "application/xml"},
+		{"protobuf message", "application/x-protobuf", wrapperspb.String("a"), "application/x-protobuf"},
+		{"protobuf falls back for non-messages", "application/x-protobuf, application/json;q=0.1", struct{}{}, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			so := require.New(t)
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tt.accept)
+			rsp := httptest.NewRecorder()
+
+			so.NoError(marshal(req, rsp, tt.v, nil))
+			so.Equal(tt.wantType, rsp.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	so := require.New(t)
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`<v><name>a</name></v>`))
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	var v struct {
+		Name string `xml:"name"`
+	}
+	so.NoError(Decode(req, &v))
+	so.Equal("a", v.Name)
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`a,b`))
+	req.Header.Set("Content-Type", "text/csv")
+	so.ErrorIs(Decode(req, &v), ErrUnsupportedMediaType)
+}
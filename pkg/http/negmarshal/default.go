@@ -2,43 +2,103 @@ package negmarshal
 
 import (
 	"context"
-	"encoding/json"
-	"encoding/xml"
 	"net/http"
 
 	"github.com/longkai/rfc7807"
 )
 
+// Style is the shape of the marshaled responses.
+type Style int
+
+const (
+	// StyleEnveloped wraps every response in the {data, error, success} envelope.
+	StyleEnveloped Style = iota
+	// StyleRaw writes the response value as is.
+	// Errors are written as bare RFC7807 problem documents with the application/problem+<format> content type.
+	StyleRaw
+)
+
+func (s Style) String() string {
+	switch s {
+	case StyleEnveloped:
+		return "enveloped"
+	case StyleRaw:
+		return "raw"
+	}
+	return "unknown"
+}
+
 type responseObject struct {
 	Data    any  `json:"data"`
 	Error   any  `json:"error"`
 	Success bool `json:"success"`
 }
 
+// MarshalerJSON marshals the responses to the JSON envelope.
 func MarshalerJSON() MarshalFunc {
+	return Marshaler(StyleEnveloped, JSON())
+}
+
+// MarshalerXML marshals the responses to the XML envelope.
+func MarshalerXML() MarshalFunc {
+	return Marshaler(StyleEnveloped, XML())
+}
+
+// MarshalerJSONRaw marshals the responses to bare JSON, and the errors to application/problem+json.
+func MarshalerJSONRaw() MarshalFunc {
+	return Marshaler(StyleRaw, JSON())
+}
+
+// MarshalerXMLRaw marshals the responses to bare XML, and the errors to application/problem+xml.
+func MarshalerXMLRaw() MarshalFunc {
+	return Marshaler(StyleRaw, XML())
+}
+
+// Marshaler builds a MarshalFunc for the given style and codec.
+//
+// ValueOnlyCodec's values are always written raw, and its errors are written as application/problem+json.
+func Marshaler(style Style, c Codec) MarshalFunc {
+	if _, ok := c.(ValueOnlyCodec); ok {
+		return valueOnly(c)
+	}
 	return func(ctx context.Context, w http.ResponseWriter, v any, errObj *rfc7807.ProblemDetail) error {
-		w.Header().Set("Content-Type", "application/json")
-		if errObj != nil && errObj.Status != 0 {
-			w.WriteHeader(errObj.Status)
+		if style == StyleEnveloped {
+			w.Header().Set("Content-Type", c.ContentType())
+			if errObj != nil && errObj.Status != 0 {
+				w.WriteHeader(errObj.Status)
+			}
+			return c.Encode(w, responseObject{
+				Data:    v,
+				Error:   errObj,
+				Success: errObj == nil,
+			})
 		}
-		return json.NewEncoder(w).Encode(responseObject{
-			Data:    v,
-			Error:   errObj,
-			Success: errObj == nil,
-		})
+
+		if errObj == nil {
+			w.Header().Set("Content-Type", c.ContentType())
+			return c.Encode(w, v)
+		}
+		return writeProblem(w, c, errObj)
 	}
 }
 
-func MarshalerXML() MarshalFunc {
+func valueOnly(c Codec) MarshalFunc {
 	return func(ctx context.Context, w http.ResponseWriter, v any, errObj *rfc7807.ProblemDetail) error {
-		w.Header().Set("Content-Type", "application/xml")
-		if errObj != nil && errObj.Status != 0 {
-			w.WriteHeader(errObj.Status)
+		if errObj != nil {
+			return writeProblem(w, JSON(), errObj)
 		}
-		return xml.NewEncoder(w).Encode(responseObject{
-			Data:    v,
-			Error:   errObj,
-			Success: errObj == nil,
-		})
+		w.Header().Set("Content-Type", c.ContentType())
+		return c.Encode(w, v)
+	}
+}
+
+// writeProblem writes the bare problem document with the codec's problem content type.
+func writeProblem(w http.ResponseWriter, c Codec, errObj *rfc7807.ProblemDetail) error {
+	w.Header().Set("Content-Type", c.ProblemContentType())
+	status := errObj.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
 	}
+	w.WriteHeader(status)
+	return c.Encode(w, errObj)
 }
@@ -3,49 +3,185 @@ package negmarshal
 import (
 	"context"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/longkai/rfc7807"
 	"github.com/utrack/caisson-go/errors"
 	"github.com/utrack/caisson-go/pkg/http/errmarshalhttp"
+	"github.com/utrack/caisson-go/pkg/observe/errobserve"
 	contentnegotiation "gitlab.com/jamietanna/content-negotiation-go"
 )
 
+// ErrNotAcceptable is returned by the negotiator when none of the accepted content types are supported.
+//
+// The negotiator writes the 406 response itself before returning the error.
+var ErrNotAcceptable = errors.Register(errors.NewCoder("NOT_ACCEPTABLE").WithHTTPCode(http.StatusNotAcceptable).WithMessage("none of the accepted content types are supported"))
+
 // MarshalFunc marshals the value in some single format (like json.Marshal or xml.Marshal).
 type MarshalFunc func(ctx context.Context, w http.ResponseWriter, rsp any, errObj *rfc7807.ProblemDetail) error
 
 // NegotiatedMarshalFunc marshals the value in the negotiated format,
 // based on the request's Accept header.
+//
+// The errObj errors are recorded on the request's span and metrics (see errobserve.Record),
+// unless it's an already built *rfc7807.ProblemDetail - its callers record the error themselves.
 type NegotiatedMarshalFunc func(r *http.Request, w http.ResponseWriter, rsp any, errObj error) error
 
-// Default returns a NegotiatedMarshalFunc that supports JSON and XML outputs.
+// Default returns a NegotiatedMarshalFunc that supports the registered formats,
+// wrapped in the {data, error, success} envelope.
 func Default() NegotiatedMarshalFunc {
-	return New(map[string]MarshalFunc{
-		"application/json": MarshalerJSON(),
-		"application/xml":  MarshalerXML(),
-	}, MarshalerJSON()).Marshal
+	return ForStyle(StyleEnveloped)
+}
+
+// ForStyle returns a NegotiatedMarshalFunc that supports the registered formats (see Register) in the given style.
+//
+// The first registered codec (JSON) is used if the client accepts any format.
+func ForStyle(style Style) NegotiatedMarshalFunc {
+	return forCodecs(style, Codecs())
+}
+
+// forCodecs returns the negotiator for the codecs, the first one is the default.
+func forCodecs(style Style, codecs []Codec) NegotiatedMarshalFunc {
+	n := &negotiator{defaultm: Marshaler(style, codecs[0])}
+	for _, c := range codecs {
+		n.add(c.ContentType(), Marshaler(style, c), c)
+	}
+	return n.Marshal
 }
 
 type negotiator struct {
-	mm       map[string]MarshalFunc
+	formats  []format
 	defaultm MarshalFunc
-	known    []string
+}
 
-	neg contentnegotiation.Negotiator
+type format struct {
+	mediaType contentnegotiation.MediaType
+	m         MarshalFunc
+	// codec is nil for the formats built from bare MarshalFuncs
+	codec Codec
 }
 
+// New returns the negotiator for the given content types' marshalers.
+// The defaultMarshaler is used if the client accepts any format.
 func New(mm map[string]MarshalFunc, defaultMarshaler MarshalFunc) *negotiator {
 	keys := make([]string, 0, len(mm))
 	for k := range mm {
 		keys = append(keys, k)
 	}
-	return &negotiator{
-		mm:       mm,
-		defaultm: defaultMarshaler,
-		known:    keys,
-		neg:      contentnegotiation.NewNegotiator(keys...),
+	sort.Strings(keys)
+
+	n := &negotiator{defaultm: defaultMarshaler}
+	for _, k := range keys {
+		n.add(k, mm[k], nil)
+	}
+	return n
+}
+
+func (n *negotiator) add(contentType string, m MarshalFunc, c Codec) {
+	mt := contentnegotiation.NewMediaType(contentType)
+	if mt == nil {
+		panic("negmarshal: invalid content type " + contentType)
+	}
+	n.formats = append(n.formats, format{mediaType: *mt, m: m, codec: c})
+}
+
+// negotiate picks the format for the Accept header.
+// The value-only formats which can't encode v are skipped, unless it's an error response.
+func (n *negotiator) negotiate(accepts string, v any, isErr bool) (MarshalFunc, bool) {
+	offers := make([]contentnegotiation.MediaType, len(n.formats))
+	for i, f := range n.formats {
+		offers[i] = f.mediaType
+	}
+	i, ok := pick(accepts, offers, func(i int) bool {
+		c := n.formats[i].codec
+		return isErr || c == nil || canEncode(c, v)
+	})
+	if !ok {
+		return nil, false
 	}
+	return n.formats[i].m, true
 }
 
+// Negotiate picks one of the offered media types for the Accept header.
+// An empty Accept header picks the first offer.
+func Negotiate(accepts string, offers ...string) (string, bool) {
+	if accepts == "" {
+		accepts = "*/*"
+	}
+	mts := make([]contentnegotiation.MediaType, 0, len(offers))
+	for _, o := range offers {
+		mt := contentnegotiation.NewMediaType(o)
+		if mt == nil {
+			panic("negmarshal: invalid content type " + o)
+		}
+		mts = append(mts, *mt)
+	}
+	i, ok := pick(accepts, mts, func(int) bool { return true })
+	if !ok {
+		return "", false
+	}
+	return offers[i], true
+}
+
+// pick returns the index of the offer for the Accept header, honoring the quality values.
+// Between the equally preferred media types, the more specific one wins;
+// between the offers matching the same media type, the first one wins.
+func pick(accepts string, offers []contentnegotiation.MediaType, acceptable func(i int) bool) (int, bool) {
+	requested := contentnegotiation.ParseAcceptHeader(accepts)
+	sort.SliceStable(requested, func(i, j int) bool {
+		if requested[i].GetQualityValue() != requested[j].GetQualityValue() {
+			return requested[i].GetQualityValue() > requested[j].GetQualityValue()
+		}
+		return specificity(requested[i]) > specificity(requested[j])
+	})
+
+	for _, mt := range requested {
+		if mt.GetQualityValue() == 0 {
+			continue
+		}
+		for i, offer := range offers {
+			if !mt.IsCompatibleWith(&offer) || rejected(requested, offer) || !acceptable(i) {
+				continue
+			}
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// rejected reports whether the media type is explicitly rejected via q=0.
+func rejected(requested []contentnegotiation.MediaType, mt contentnegotiation.MediaType) bool {
+	for _, r := range requested {
+		if r.GetQualityValue() == 0 && r.GetType() == mt.GetType() && r.GetSubType() == mt.GetSubType() {
+			return true
+		}
+	}
+	return false
+}
+
+func specificity(mt contentnegotiation.MediaType) int {
+	switch {
+	case mt.IsWildcardType():
+		return 0
+	case mt.IsWildcardSubType():
+		return 1
+	}
+	return 2
+}
+
+func (n *negotiator) known() string {
+	ret := make([]string, 0, len(n.formats))
+	for _, f := range n.formats {
+		ret = append(ret, f.mediaType.String())
+	}
+	return strings.Join(ret, ", ")
+}
+
+// Marshal writes either the value or the error in the format negotiated via the request's Accept header.
+//
+// If the negotiation fails, it writes a 406 Not Acceptable problem in the default format
+// and returns an error wrapping [ErrNotAcceptable].
 func (n *negotiator) Marshal(r *http.Request, w http.ResponseWriter, v any, errObj error) error {
 	var errRFC *rfc7807.ProblemDetail
 
@@ -53,6 +189,8 @@ func (n *negotiator) Marshal(r *http.Request, w http.ResponseWriter, v any, errO
 		var ok bool
 		errRFC, ok = errObj.(*rfc7807.ProblemDetail)
 		if !ok {
+			errobserve.Record(r.Context(), errObj)
+			errmarshalhttp.SetHeaders(w.Header(), errObj)
 			errRFC = errmarshalhttp.ToRFC7807(r.Context(), errObj)
 		}
 	}
@@ -64,13 +202,17 @@ func (n *negotiator) Marshal(r *http.Request, w http.ResponseWriter, v any, errO
 		}
 		return m(r.Context(), w, v, errRFC)
 	}
-	mType, _, err := n.neg.Negotiate(accepts)
-	if err != nil {
-		return errors.Wrapd(err, "failed to negotiate content type", "accepts", accepts, "supported", n.known)
-	}
-	m, ok := n.mm[mType.String()]
+	m, ok := n.negotiate(accepts, v, errRFC != nil)
 	if !ok {
-		panic("something's really wrong, no marshaler for known-negotiated type " + mType.String())
+		err := ErrNotAcceptable.Wrap(errors.Wrapd(errors.New("no supported content type is accepted"), "failed to negotiate content type", "accepts", accepts, "supported", n.known()))
+		if n.defaultm == nil {
+			return err
+		}
+		// respond in the default format, since the client didn't accept any of ours anyway
+		if merr := n.defaultm(r.Context(), w, nil, errmarshalhttp.ToRFC7807(r.Context(), err)); merr != nil {
+			return errors.Wrap(merr, "when writing 406 Not Acceptable response")
+		}
+		return err
 	}
 	return m(r.Context(), w, v, errRFC)
 }
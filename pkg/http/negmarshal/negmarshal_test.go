@@ -0,0 +1,81 @@
+package negmarshal
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/longkai/rfc7807"
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestForStyle(t *testing.T) {
+	errNotFound := errors.NewCoder("NOT_FOUND").WithHTTPCode(404).WithMessage("not found")
+
+	type out struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		style       Style
+		v           any
+		err         error
+		wantStatus  int
+		wantType    string
+		wantBodyStr string
+	}{
+		{"enveloped ok", StyleEnveloped, out{"a"}, nil, 200, "application/json", `{"data":{"name":"a"},"error":null,"success":true}`},
+		{"raw ok", StyleRaw, out{"a"}, nil, 200, "application/json", `{"name":"a"}`},
+		{"raw error", StyleRaw, nil, errNotFound.Wrap(errors.New("no rows")), 404, "application/problem+json", `"type":"NOT_FOUND"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			so := require.New(t)
+			rsp := httptest.NewRecorder()
+			err := ForStyle(tt.style)(httptest.NewRequest("GET", "/", nil), rsp, tt.v, tt.err)
+			so.NoError(err)
+			so.Equal(tt.wantStatus, rsp.Code)
+			so.Equal(tt.wantType, rsp.Header().Get("Content-Type"))
+			so.Contains(rsp.Body.String(), tt.wantBodyStr)
+		})
+	}
+}
+
+func TestNegotiator_notAcceptable(t *testing.T) {
+	so := require.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/csv")
+	rsp := httptest.NewRecorder()
+
+	err := Default()(req, rsp, struct{}{}, nil)
+	so.True(errors.Is(err, ErrNotAcceptable))
+	so.Equal(406, rsp.Code)
+	so.Equal("application/json", rsp.Header().Get("Content-Type"))
+}
+
+func TestNegotiator_recordsErrors(t *testing.T) {
+	so := require.New(t)
+
+	rec := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test")
+
+	ctx, span := tracer.Start(context.Background(), "error")
+	err := Default()(httptest.NewRequest("GET", "/", nil).WithContext(ctx), httptest.NewRecorder(), nil, errors.New("boom"))
+	so.NoError(err)
+	span.End()
+
+	// the problems are recorded by their builders
+	ctx, span = tracer.Start(context.Background(), "problem")
+	err = Default()(httptest.NewRequest("GET", "/", nil).WithContext(ctx), httptest.NewRecorder(), nil, &rfc7807.ProblemDetail{Status: 500})
+	so.NoError(err)
+	span.End()
+
+	so.Equal(codes.Error, rec.Ended()[0].Status().Code)
+	so.Equal(codes.Unset, rec.Ended()[1].Status().Code)
+}
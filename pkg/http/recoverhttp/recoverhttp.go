@@ -0,0 +1,72 @@
+/*
+Package recoverhttp provides an HTTP middleware which recovers from the handlers' panics.
+
+Unlike chi's Recoverer, it treats a panic like any other handler error:
+the panic is converted to a Coded error with the captured stack, logged via [github.com/utrack/caisson-go/log],
+recorded on the request's span and marshaled to the client in the negotiated format.
+*/
+package recoverhttp
+
+import (
+	"net/http"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/pkg/http/errmarshalhttp"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/observe/errobserve"
+)
+
+// ErrPanic is returned to the client when the handler panics.
+var ErrPanic = errors.Register(errors.NewCoder("INTERNAL_PANIC").WithHTTPCode(500).WithMessage("internal server error"))
+
+// Middleware recovers from the panics in the downstream handlers.
+//
+// http.ErrAbortHandler panics are passed through untouched.
+// If the handler has already written the response headers when it panicked,
+// the panic is logged and the connection is aborted via http.ErrAbortHandler,
+// so that the client won't mistake a partial response for a complete one.
+func Middleware(marshaler negmarshal.NegotiatedMarshalFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				ctx := r.Context()
+				err := ErrPanic.Wrap(panicError(rec))
+				headersWritten := ww.Status() != 0
+
+				log.Error(ctx, "panic recovered while serving HTTP request", err, "http.headers_written", headersWritten)
+
+				errobserve.Record(ctx, err)
+				if headersWritten {
+					panic(http.ErrAbortHandler)
+				}
+
+				problem := errmarshalhttp.ToRFC7807(ctx, err)
+				if merr := marshaler(r, ww, nil, problem); merr != nil {
+					log.Error(ctx, "failed to marshal the panic response", merr)
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// panicError converts the recovered value to an error with the stack trace of the panic.
+func panicError(rec any) error {
+	if err, ok := rec.(error); ok {
+		return errors.WithStack(errors.WithMessage(err, "panic"))
+	}
+	return errors.Errorf("panic: %v", rec)
+}
@@ -0,0 +1,56 @@
+package recoverhttp_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/log/logtest"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"github.com/utrack/caisson-go/pkg/http/recoverhttp"
+)
+
+func TestMiddleware(t *testing.T) {
+	so := require.New(t)
+	ctx, logs := logtest.New(t)
+
+	mw := recoverhttp.Middleware(negmarshal.Default())
+
+	hdl := mw(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	rsp := httptest.NewRecorder()
+	hdl.ServeHTTP(rsp, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+
+	so.Equal(http.StatusInternalServerError, rsp.Code)
+	var body struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	so.NoError(json.Unmarshal(rsp.Body.Bytes(), &body))
+	so.Equal("INTERNAL_PANIC", body.Error.Type)
+	logs.AssertLogged(t, slog.LevelError, "panic recovered", "error.code", "INTERNAL_PANIC", "error.message", "panic: boom")
+
+	// headers are already written - abort the connection
+	hdl = mw(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	}))
+	so.PanicsWithValue(http.ErrAbortHandler, func() {
+		hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	})
+
+	// aborts are passed through without logging
+	logs.Reset()
+	hdl = mw(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	so.PanicsWithValue(http.ErrAbortHandler, func() {
+		hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	})
+	so.Empty(logs.Records())
+}
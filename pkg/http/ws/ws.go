@@ -0,0 +1,407 @@
+/*
+Package ws provides the typed WebSocket connections for the handlers bound via httpbinding.
+
+A handler shaped like
+
+	func(ctx context.Context, in Input, conn *ws.Conn[Req, Resp]) error
+
+is bound as a WebSocket endpoint: the input is decoded and validated as usual,
+then the connection is upgraded and the handler exchanges the typed messages until it returns.
+
+The messages are encoded with the negmarshal codecs, negotiated via the Sec-WebSocket-Protocol header:
+the subprotocols are the codecs' media subtypes, like json, msgpack or cbor. JSON is used if the client offers none.
+
+The handler's context carries the request's trace and log context and is canceled when the connection closes.
+Shutdown() closes the active connections with the 1001 Going Away frames and rejects the new ones;
+caiapp calls it during the graceful shutdown.
+*/
+package ws
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/coder/websocket"
+	"github.com/go-chi/chi/v5"
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
+	"github.com/utrack/caisson-go/pkg/http/negmarshal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// StatusCode is the WebSocket close status code.
+type StatusCode = websocket.StatusCode
+
+const (
+	StatusNormalClosure   = websocket.StatusNormalClosure
+	StatusGoingAway       = websocket.StatusGoingAway
+	StatusPolicyViolation = websocket.StatusPolicyViolation
+	StatusInternalError   = websocket.StatusInternalError
+)
+
+// Options configure the connections' upgrade.
+type Options struct {
+	// OriginPatterns are the host patterns of the allowed cross-origin requests;
+	// see websocket.AcceptOptions.
+	OriginPatterns []string
+	// ReadLimit is the maximum size of the received messages; the default is 32KiB.
+	ReadLimit int64
+	// Registry tracks the connections for the graceful shutdown; the default one is shut down via Shutdown.
+	Registry *Registry
+}
+
+func (o Options) registry() *Registry {
+	if o.Registry == nil {
+		return active
+	}
+	return o.Registry
+}
+
+// ErrShuttingDown is returned by Accept once the registry is shut down.
+// It's expected during the deployments, so it doesn't fail the spans.
+var ErrShuttingDown = errors.Register(errors.NewCoder("SHUTTING_DOWN").WithHTTPCode(http.StatusServiceUnavailable).WithMessage("server is shutting down").
+	WithSeverity(errors.SeverityExpected))
+
+// Socket is an untyped WebSocket connection; see Conn for the typed one.
+type Socket struct {
+	c       *websocket.Conn
+	codec   negmarshal.Codec
+	msgType websocket.MessageType
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+	route     string
+	reg       *Registry
+}
+
+// Accept upgrades the request to the WebSocket connection.
+//
+// On failure, the HTTP error response is already written, except for ErrShuttingDown:
+// the registry is shut down, the request is left intact for the caller to respond with the error.
+// The socket should be closed via Close().
+func Accept(w http.ResponseWriter, r *http.Request, o Options) (*Socket, error) {
+	reg := o.registry()
+	if reg.isClosing() {
+		return nil, ErrShuttingDown.Wrap(errors.New("WebSocket upgrade rejected"))
+	}
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols:   Subprotocols(),
+		OriginPatterns: o.OriginPatterns,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to upgrade to WebSocket")
+	}
+	if o.ReadLimit > 0 {
+		c.SetReadLimit(o.ReadLimit)
+	}
+
+	codec := negmarshal.JSON()
+	for _, cd := range negmarshal.Codecs() {
+		if c.Subprotocol() != "" && subprotocol(cd) == c.Subprotocol() {
+			codec = cd
+		}
+	}
+
+	s := &Socket{c: c, codec: codec, msgType: websocket.MessageBinary, reg: reg}
+	if textual(codec.ContentType()) {
+		s.msgType = websocket.MessageText
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		s.route = rctx.RoutePattern()
+	}
+
+	ctx := log.With(r.Context(), "ws.format", codec.ContentType())
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	if !reg.add(s) {
+		// the registry has been shut down during the upgrade; the response is written already
+		_ = s.Close(StatusGoingAway, "server is shutting down")
+		return nil, errors.New("WebSocket connection closed: server is shutting down")
+	}
+	return s, nil
+}
+
+// Context is canceled when the socket is closed.
+func (s *Socket) Context() context.Context {
+	return s.ctx
+}
+
+// ContentType is the media type of the negotiated codec.
+func (s *Socket) ContentType() string {
+	return s.codec.ContentType()
+}
+
+// Receive reads the next message into v.
+func (s *Socket) Receive(ctx context.Context, v any) error {
+	_, r, err := s.c.Reader(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.codec.Decode(r, v); err != nil {
+		return errors.Wrapf(err, "when decoding %v message", s.codec.ContentType())
+	}
+	return nil
+}
+
+// Send writes v as a single message.
+func (s *Socket) Send(ctx context.Context, v any) error {
+	w, err := s.c.Writer(ctx, s.msgType)
+	if err != nil {
+		return err
+	}
+	if err := s.codec.Encode(w, v); err != nil {
+		_ = w.Close()
+		return errors.Wrapf(err, "when encoding %v message", s.codec.ContentType())
+	}
+	return w.Close()
+}
+
+// Close sends the close frame and closes the socket; subsequent calls do nothing.
+func (s *Socket) Close(code StatusCode, reason string) error {
+	var err error
+	s.closeOnce.Do(func() {
+		// close frames' reasons are limited to 123 bytes
+		if len(reason) > 123 {
+			reason = reason[:123]
+		}
+		err = s.c.Close(code, reason)
+		s.cancel()
+		s.reg.remove(s)
+	})
+	return err
+}
+
+// CloseError closes the socket with the status derived from the handler's error:
+// nil is the normal closure, 4xx Coded errors become the 4000+HTTP code application statuses
+// and the rest are the internal errors. The reason is the Coded error's message.
+func (s *Socket) CloseError(err error) error {
+	if err == nil || IsClosed(err) {
+		return s.Close(StatusNormalClosure, "")
+	}
+	code, reason := StatusInternalError, "internal error"
+	if c := errors.Code(err); c != nil {
+		if c.HTTPCode() >= 400 && c.HTTPCode() < 500 {
+			code = StatusCode(4000 + c.HTTPCode())
+		}
+		reason = c.Message()
+		if reason == "" {
+			reason = c.Type()
+		}
+	}
+	return s.Close(code, reason)
+}
+
+// IsClosed reports whether the error is caused by the closed connection.
+func IsClosed(err error) bool {
+	return websocket.CloseStatus(err) != -1 ||
+		errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed)
+}
+
+// Subprotocols lists the supported subprotocols, one per registered negmarshal codec.
+func Subprotocols() []string {
+	codecs := negmarshal.Codecs()
+	ret := make([]string, 0, len(codecs))
+	for _, c := range codecs {
+		ret = append(ret, subprotocol(c))
+	}
+	return ret
+}
+
+// subprotocol is the codec's WebSocket subprotocol, like json for application/json.
+func subprotocol(c negmarshal.Codec) string {
+	_, sub, _ := strings.Cut(c.ContentType(), "/")
+	return sub
+}
+
+func textual(contentType string) bool {
+	switch contentType {
+	case "application/json", "application/xml", "application/yaml", "application/x-ndjson":
+		return true
+	}
+	return strings.HasPrefix(contentType, "text/")
+}
+
+// Conn is a WebSocket connection receiving Req and sending Resp messages.
+type Conn[Req, Resp any] struct {
+	s *Socket
+}
+
+// Receive reads the next message.
+func (c *Conn[Req, Resp]) Receive(ctx context.Context) (Req, error) {
+	var ret Req
+	err := c.s.Receive(ctx, &ret)
+	return ret, err
+}
+
+// Send writes a single message.
+func (c *Conn[Req, Resp]) Send(ctx context.Context, v Resp) error {
+	return c.s.Send(ctx, v)
+}
+
+// Close closes the connection with the status code and reason.
+// The binding closes the connection after the handler returns anyway.
+func (c *Conn[Req, Resp]) Close(code StatusCode, reason string) error {
+	return c.s.Close(code, reason)
+}
+
+// ContentType is the media type of the negotiated codec.
+func (c *Conn[Req, Resp]) ContentType() string {
+	return c.s.ContentType()
+}
+
+func (c *Conn[Req, Resp]) bind(s *Socket) {
+	c.s = s
+}
+
+func (c *Conn[Req, Resp]) messageTypes() (reflect.Type, reflect.Type) {
+	return reflect.TypeFor[Req](), reflect.TypeFor[Resp]()
+}
+
+// conn is implemented by *Conn.
+type conn interface {
+	bind(s *Socket)
+	messageTypes() (reflect.Type, reflect.Type)
+}
+
+var connType = reflect.TypeFor[conn]()
+
+// IsConn reports whether t is *Conn[Req, Resp].
+func IsConn(t reflect.Type) bool {
+	return t.Kind() == reflect.Pointer && t.Implements(connType)
+}
+
+// NewConn creates the *Conn[Req, Resp] of type t over the socket; t should satisfy IsConn.
+func NewConn(t reflect.Type, s *Socket) reflect.Value {
+	ret := reflect.New(t.Elem())
+	ret.Interface().(conn).bind(s)
+	return ret
+}
+
+// MessageTypes returns the Req and Resp types of *Conn[Req, Resp].
+func MessageTypes(t reflect.Type) (req reflect.Type, resp reflect.Type) {
+	return reflect.Zero(t).Interface().(conn).messageTypes()
+}
+
+// Registry tracks the active sockets for the graceful shutdown.
+type Registry struct {
+	mu      sync.Mutex
+	sockets map[*Socket]struct{}
+	// closing is set by Shutdown; no sockets are added after that
+	closing bool
+	// drained is closed once the sockets are gone after Shutdown
+	drained chan struct{}
+
+	gauge metric.Int64UpDownCounter
+}
+
+// active is the default registry.
+var active = NewRegistry()
+
+// NewRegistry returns an empty registry; see Options.Registry.
+func NewRegistry() *Registry {
+	gauge, err := otel.Meter("github.com/utrack/caisson-go/pkg/http/ws").Int64UpDownCounter(
+		"http.server.websocket.active_connections",
+		metric.WithDescription("Number of the active WebSocket connections"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &Registry{sockets: map[*Socket]struct{}{}, gauge: gauge}
+}
+
+func (r *Registry) isClosing() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closing
+}
+
+// Len returns the number of the active sockets.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sockets)
+}
+
+// add registers the socket; it returns false if the registry is closing.
+func (r *Registry) add(s *Socket) bool {
+	r.mu.Lock()
+	if r.closing {
+		r.mu.Unlock()
+		return false
+	}
+	r.sockets[s] = struct{}{}
+	r.mu.Unlock()
+	r.gauge.Add(s.ctx, 1, metric.WithAttributes(attribute.String("http.route", s.route)))
+	return true
+}
+
+func (r *Registry) remove(s *Socket) {
+	r.mu.Lock()
+	if _, ok := r.sockets[s]; !ok {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.sockets, s)
+	if len(r.sockets) == 0 && r.drained != nil {
+		close(r.drained)
+		r.drained = nil
+	}
+	r.mu.Unlock()
+	r.gauge.Add(context.WithoutCancel(s.ctx), -1, metric.WithAttributes(attribute.String("http.route", s.route)))
+}
+
+// close stops accepting the sockets and returns the active ones,
+// along with the channel closed once all of them are removed.
+func (r *Registry) close() ([]*Socket, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closing = true
+
+	sockets := make([]*Socket, 0, len(r.sockets))
+	for s := range r.sockets {
+		sockets = append(sockets, s)
+	}
+	drained := make(chan struct{})
+	if len(sockets) == 0 {
+		close(drained)
+	} else {
+		r.drained = drained
+	}
+	return sockets, drained
+}
+
+// Shutdown stops accepting the connections, then closes the active ones with the 1001 Going Away frames,
+// waiting for the clients to acknowledge them until ctx is done.
+// The upgrades attempted after that fail with ErrShuttingDown.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	sockets, drained := r.close()
+	for _, s := range sockets {
+		go func() {
+			_ = s.Close(StatusGoingAway, "server is shutting down")
+		}()
+	}
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		for _, s := range sockets {
+			_ = s.c.CloseNow()
+		}
+		return errors.Wrapf(ctx.Err(), "when closing %d WebSocket connections", len(sockets))
+	}
+}
+
+// Shutdown shuts the default registry down; see Registry.Shutdown.
+func Shutdown(ctx context.Context) error {
+	return active.Shutdown(ctx)
+}
@@ -0,0 +1,130 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+)
+
+type message struct {
+	N int `json:"n"`
+}
+
+// serve starts the server upgrading the requests via the registry and echoing the messages
+// until the handler fails; the handlers' errors close the sockets.
+func serve(t *testing.T, reg *Registry) string {
+	errNegative := errors.NewCoder("NEGATIVE").WithHTTPCode(422).WithMessage("negative")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := Accept(w, r, Options{Registry: reg})
+		if errors.Is(err, ErrShuttingDown) {
+			w.WriteHeader(errors.Code(err).HTTPCode())
+			return
+		}
+		if err != nil {
+			return
+		}
+		for {
+			var msg message
+			if err = s.Receive(s.Context(), &msg); err != nil {
+				break
+			}
+			if msg.N < 0 {
+				err = errNegative.Wrap(errors.New("n < 0"))
+				break
+			}
+			if err = s.Send(s.Context(), msg); err != nil {
+				break
+			}
+		}
+		_ = s.CloseError(err)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestAccept(t *testing.T) {
+	so := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	url := serve(t, NewRegistry())
+
+	c, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{Subprotocols: []string{"json"}})
+	so.NoError(err)
+	defer c.CloseNow()
+	so.Equal("json", c.Subprotocol())
+
+	so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":2}`)))
+	typ, buf, err := c.Read(ctx)
+	so.NoError(err)
+	so.Equal(websocket.MessageText, typ)
+	so.JSONEq(`{"n":2}`, string(buf))
+
+	// 4xx Coded errors become the 4000+ statuses
+	so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":-1}`)))
+	_, _, err = c.Read(ctx)
+	so.Equal(StatusCode(4422), websocket.CloseStatus(err))
+}
+
+func TestRegistry_Shutdown(t *testing.T) {
+	so := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reg := NewRegistry()
+	url := serve(t, reg)
+
+	c, _, err := websocket.Dial(ctx, url, nil)
+	so.NoError(err)
+	defer c.CloseNow()
+
+	// the reply means the socket is registered
+	so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":1}`)))
+	_, _, err = c.Read(ctx)
+	so.NoError(err)
+	so.Equal(1, reg.Len())
+
+	readErr := make(chan error, 1)
+	go func() {
+		_, _, err := c.Read(ctx)
+		readErr <- err
+	}()
+	so.NoError(reg.Shutdown(ctx))
+	so.Equal(StatusGoingAway, websocket.CloseStatus(<-readErr))
+	so.Zero(reg.Len())
+
+	_, rsp, err := websocket.Dial(ctx, url, nil)
+	so.Error(err)
+	so.Equal(http.StatusServiceUnavailable, rsp.StatusCode)
+
+	// the other registries are intact
+	c, _, err = websocket.Dial(ctx, serve(t, NewRegistry()), nil)
+	so.NoError(err)
+	_ = c.CloseNow()
+}
+
+func TestRegistry_ShutdownTimeout(t *testing.T) {
+	so := require.New(t)
+	reg := NewRegistry()
+	url := serve(t, reg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, _, err := websocket.Dial(ctx, url, nil)
+	so.NoError(err)
+	defer c.CloseNow()
+	so.NoError(c.Write(ctx, websocket.MessageText, []byte(`{"n":1}`)))
+	_, _, err = c.Read(ctx)
+	so.NoError(err)
+
+	// the client never reads, so the close frame isn't acknowledged
+	expired, cancelExpired := context.WithCancel(context.Background())
+	cancelExpired()
+	so.ErrorIs(reg.Shutdown(expired), context.Canceled)
+}
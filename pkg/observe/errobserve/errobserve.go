@@ -0,0 +1,180 @@
+/*
+Package errobserve records the errors returned to the clients on the spans and metrics,
+according to their severity (see errors.Severity):
+
+  - the expected errors only annotate the span with their error.type, severity and data;
+  - the warnings are recorded on the span as exceptions;
+  - the critical errors fail the span as well, and increment the caisson.errors counter.
+
+Only the severity decides whether the span fails, so that an expected 5xx
+(e.g. a draining instance's 503) doesn't page anyone.
+The errors' key-value pairs (see errorbag.ListPairs), except for the Coded itself,
+are set as the span's attributes under the error.data. prefix,
+so that they don't collide with the semantic conventions.
+*/
+package errobserve
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/levels/level3/errorbag"
+	"github.com/utrack/caisson-go/pkg/observe/tracer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ErrorTypeKey is the error's Coded type; OtherErrorType for the uncoded errors.
+	ErrorTypeKey = attribute.Key("error.type")
+	// ErrorSeverityKey is the error's severity.
+	ErrorSeverityKey = attribute.Key("error.severity")
+	// ErrorDataPrefix prefixes the keys of the errors' key-value pairs.
+	ErrorDataPrefix = "error.data."
+)
+
+// OtherErrorType is the error.type of the uncoded errors, as per the semantic conventions.
+const OtherErrorType = "_OTHER"
+
+var counter = func() metric.Int64Counter {
+	c, err := otel.Meter("github.com/utrack/caisson-go/pkg/observe/errobserve").Int64Counter(
+		"caisson.errors",
+		metric.WithDescription("Number of the critical errors returned to the clients"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	return c
+}()
+
+// Record records the error on the context's span and metrics according to its severity.
+func Record(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	sev := errors.SeverityOf(err)
+	typ := OtherErrorType
+	if c := errors.Code(err); c != nil {
+		typ = c.Type()
+	}
+	attrs := []attribute.KeyValue{ErrorTypeKey.String(typ), ErrorSeverityKey.String(sev.String())}
+
+	if sev == errors.SeverityCritical {
+		counter.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(attrs...)
+	span.SetAttributes(dataAttributes(errorbag.ListPairs(err))...)
+	if sev >= errors.SeverityWarning {
+		tracer.RecordError(span, err)
+	}
+	if sev == errors.SeverityCritical {
+		span.SetStatus(codes.Error, typ)
+	}
+}
+
+// codedKey is the Coded pair's key in errorbag.ListPairs; it's exported as error.type instead.
+var codedKey = reflect.TypeFor[errors.Coded]().String()
+
+// dataAttributes converts the pairs to the typed attributes, sorted by their keys.
+func dataAttributes(pairs map[string]any) []attribute.KeyValue {
+	ret := make([]attribute.KeyValue, 0, len(pairs))
+	for k, v := range pairs {
+		if k == codedKey {
+			continue
+		}
+		ret = append(ret, attribute.KeyValue{Key: attribute.Key(ErrorDataPrefix + k), Value: attributeValue(v)})
+	}
+	slices.SortFunc(ret, func(a, b attribute.KeyValue) int { return strings.Compare(string(a.Key), string(b.Key)) })
+	return ret
+}
+
+// attributeValue maps the value to the attribute.Value of its kind;
+// the values of the other kinds are formatted via %v.
+func attributeValue(v any) attribute.Value {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return attribute.StringValue(rv.String())
+	case reflect.Bool:
+		return attribute.BoolValue(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return attribute.Int64Value(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return attribute.Int64Value(int64(u))
+		}
+	case reflect.Float32, reflect.Float64:
+		return attribute.Float64Value(rv.Float())
+	case reflect.Pointer:
+		if !rv.IsNil() {
+			return attributeValue(rv.Elem().Interface())
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		return sliceValue(rv)
+	}
+	if s, ok := v.(fmt.Stringer); ok {
+		return attribute.StringValue(s.String())
+	}
+	return attribute.StringValue(fmt.Sprintf("%v", v))
+}
+
+// sliceValue maps the slice to the attribute's slice of its elements' kind;
+// the mixed slices, like the pairs of the duplicate keys, become the string slices.
+func sliceValue(rv reflect.Value) attribute.Value {
+	elems := make([]attribute.Value, rv.Len())
+	for i := range elems {
+		elems[i] = attributeValue(rv.Index(i).Interface())
+	}
+	typ := attribute.STRING
+	if len(elems) > 0 {
+		typ = elems[0].Type()
+	}
+	for _, e := range elems {
+		if e.Type() != typ {
+			typ = attribute.STRING
+			break
+		}
+	}
+
+	switch typ {
+	case attribute.BOOL:
+		ret := make([]bool, len(elems))
+		for i, e := range elems {
+			ret[i] = e.AsBool()
+		}
+		return attribute.BoolSliceValue(ret)
+	case attribute.INT64:
+		ret := make([]int64, len(elems))
+		for i, e := range elems {
+			ret[i] = e.AsInt64()
+		}
+		return attribute.Int64SliceValue(ret)
+	case attribute.FLOAT64:
+		ret := make([]float64, len(elems))
+		for i, e := range elems {
+			ret[i] = e.AsFloat64()
+		}
+		return attribute.Float64SliceValue(ret)
+	}
+	ret := make([]string, len(elems))
+	for i, e := range elems {
+		ret[i] = e.Emit()
+	}
+	return attribute.StringSliceValue(ret)
+}
@@ -0,0 +1,70 @@
+package errobserve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecord(t *testing.T) {
+	cases := []struct {
+		name       string
+		severity   string
+		err        error
+		status     codes.Code
+		exceptions int
+	}{
+		{"expected", "expected", errors.NewCoder("NOT_FOUND").WithHTTPCode(404).Wrap(errors.New("x")), codes.Unset, 0},
+		{"warning", "warning", errors.NewCoder("CONFLICT").WithHTTPCode(409).WithSeverity(errors.SeverityWarning).Wrap(errors.New("x")), codes.Unset, 1},
+		{"critical", "critical", errors.NewCoder("DB_DOWN").WithHTTPCode(503).Wrap(errors.New("x")), codes.Error, 1},
+		{"expected 5xx", "expected", errors.NewCoder("DRAINING").WithHTTPCode(503).WithSeverity(errors.SeverityExpected).Wrap(errors.New("x")), codes.Unset, 0},
+		{"uncoded", "critical", errors.New("boom"), codes.Error, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			so := require.New(t)
+
+			rec := tracetest.NewSpanRecorder()
+			ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test").Start(context.Background(), "op")
+			Record(ctx, c.err)
+			span.End()
+
+			s := rec.Ended()[0]
+			so.Equal(c.status, s.Status().Code)
+			so.Len(s.Events(), c.exceptions)
+			so.Contains(s.Attributes(), ErrorSeverityKey.String(c.severity))
+		})
+	}
+}
+
+func TestRecord_attributes(t *testing.T) {
+	so := require.New(t)
+
+	rec := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)).Tracer("test").Start(context.Background(), "op")
+
+	draining := errors.NewCoder("DRAINING").WithHTTPCode(503).WithSeverity(errors.SeverityExpected)
+	err := errors.Wrapd(draining.Wrap(errors.New("x")), "call", "attempt", 3, "shards", []int{1, 2}, "forced", true, "node", "n1")
+	Record(ctx, err)
+	span.End()
+
+	s := rec.Ended()[0]
+	so.Equal(codes.Unset, s.Status().Code)
+	so.Empty(s.Events())
+
+	attrs := s.Attributes()
+	so.Contains(attrs, ErrorTypeKey.String("DRAINING"))
+	so.Contains(attrs, attribute.Int64("error.data.attempt", 3))
+	so.Contains(attrs, attribute.Int64Slice("error.data.shards", []int64{1, 2}))
+	so.Contains(attrs, attribute.Bool("error.data.forced", true))
+	so.Contains(attrs, attribute.String("error.data.node", "n1"))
+	for _, a := range attrs {
+		so.NotEqual(attribute.Key("error.data.errors.Coded"), a.Key)
+	}
+}
@@ -0,0 +1,23 @@
+package tracer
+
+import (
+	"github.com/utrack/caisson-go/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExceptionStacktraceKey is the span event attribute carrying the exception's stack trace.
+const ExceptionStacktraceKey = attribute.Key("exception.stacktrace")
+
+// RecordError records the error as the span's exception event.
+// The exception.stacktrace is the stack captured in the error chain (see errors.Stack),
+// matching the error.stack of the logs, rather than the stack of the RecordError's caller.
+func RecordError(span trace.Span, err error, opts ...trace.EventOption) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	if st := errors.Stack(err); len(st.Frames) > 0 || len(st.Wraps) > 0 {
+		opts = append(opts, trace.WithAttributes(ExceptionStacktraceKey.String(st.String())))
+	}
+	span.RecordError(err, opts...)
+}
@@ -0,0 +1,51 @@
+package tracer
+
+import (
+	"context"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type ctxForceSampleKeyType string
+
+const ctxForceSampleKey ctxForceSampleKeyType = "caisson.tracer.forcesample"
+
+// ForceSample marks the context so that any span started with it is sampled,
+// regardless of the sampling ratio.
+//
+// It works only if the tracer provider uses the [NewSampler] sampler.
+func ForceSample(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxForceSampleKey, true)
+}
+
+// IsForcedSample reports whether the context was marked via [ForceSample].
+func IsForcedSample(ctx context.Context) bool {
+	ret, _ := ctx.Value(ctxForceSampleKey).(bool)
+	return ret
+}
+
+// NewSampler returns a sampler that samples the spans started with a [ForceSample] context,
+// and consults the base sampler for the rest.
+// Wrap the base in sdktrace.ParentBased to honor the upstream sampling decisions.
+func NewSampler(base sdktrace.Sampler) sdktrace.Sampler {
+	return forceSampler{base: base}
+}
+
+type forceSampler struct {
+	base sdktrace.Sampler
+}
+
+func (s forceSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if p.ParentContext != nil && IsForcedSample(p.ParentContext) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return s.base.ShouldSample(p)
+}
+
+func (s forceSampler) Description() string {
+	return "ForceSampler{" + s.base.Description() + "}"
+}
@@ -1,8 +1,10 @@
 package plconfig
 
 import (
+	"log/slog"
 	"runtime/debug"
 	"strings"
+	"time"
 
 	"github.com/utrack/caisson-go/errors"
 	"github.com/utrack/envconfig"
@@ -10,13 +12,57 @@ import (
 
 type Config struct {
 	ServiceName string
-	Otel        TelemetryConfig
+	// Mode is the environment's mode; see ModeProduction and ModeDevelopment.
+	Mode Mode `default:"production"`
+	Otel TelemetryConfig
+	Log  LogConfig
 }
 
+// Mode is the environment's mode.
+type Mode string
+
+const (
+	// ModeProduction hides the internal error details from the API clients;
+	// they are available in the logs and traces only.
+	ModeProduction Mode = "production"
+	// ModeDevelopment exposes the internal error details to the API clients.
+	ModeDevelopment Mode = "development"
+)
+
 type TelemetryConfig struct {
 	Enable            bool `required:"true"` // required so that the telemetry isn't accidentally off on prod (explicit v implicit)
 	CollectorEndpoint string
 	CollectorInsecure bool
+	// SampleRatio is the ratio of the traces being sampled, from 0 to 1.
+	// Requests in debug mode are sampled regardless.
+	SampleRatio float64 `default:"1"`
+}
+
+type LogConfig struct {
+	// Level is the minimum level of the logs being emitted.
+	// Requests in debug mode log with the Debug level regardless.
+	Level slog.Level `default:"DEBUG"`
+	Dedup LogDedupConfig
+	Stack LogStackConfig
+}
+
+// LogStackConfig configures the stack traces of the logged errors and the spans' exceptions.
+// See [github.com/utrack/caisson-go/errors.Stack] for details.
+type LogStackConfig struct {
+	// KeepStdlib keeps the frames of the runtime and the standard library.
+	KeepStdlib bool
+	// MaxFrames limits the number of the frames; zero means no limit.
+	MaxFrames int `default:"32"`
+}
+
+// LogDedupConfig configures the rate limiting of similar log records.
+// See [github.com/utrack/caisson-go/pkg/slogdedup] for details.
+type LogDedupConfig struct {
+	Enable bool
+	// Interval is the rate limiting window for every kind of log record.
+	Interval time.Duration `default:"10s"`
+	// Burst is the number of similar log records passed through per Interval.
+	Burst int `default:"10"`
 }
 
 func read() (*Config, error) {
@@ -35,6 +81,10 @@ func read() (*Config, error) {
 
 	c.ServiceName = strings.ReplaceAll(c.ServiceName, "/", "-")
 
+	if c.Mode != ModeProduction && c.Mode != ModeDevelopment {
+		return nil, errors.Errorf("caisson/baseconfig: MODE should be either %q or %q, got %q", ModeProduction, ModeDevelopment, c.Mode)
+	}
+
 	if c.Otel.Enable && c.Otel.CollectorEndpoint == "" {
 		return nil, errors.Errorf("caisson/baseconfig: OTEL_COLLECTOR_ENDPOINT is required when OTEL_ENABLE is true")
 	}
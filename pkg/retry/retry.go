@@ -0,0 +1,88 @@
+/*
+Package retry retries the operations failing with the retryable errors (see errors.IsRetryable).
+
+The delays grow exponentially with the full jitter: every delay is a random duration
+between zero and the current backoff. The delays requested by the errors via Coder.RetryAfter take precedence.
+*/
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/utrack/caisson-go/errors"
+	"github.com/utrack/caisson-go/log"
+)
+
+// Policy configures the retries; the zero values are replaced with the defaults.
+type Policy struct {
+	// MaxAttempts is the number of the attempts, including the first one. Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the first delay. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff's growth. Defaults to 10s.
+	MaxBackoff time.Duration
+	// Multiplier is the backoff's growth factor per attempt. Defaults to 2.
+	Multiplier float64
+	// Retryable classifies the errors. Defaults to errors.IsRetryable.
+	Retryable func(error) bool
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 10 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Retryable == nil {
+		p.Retryable = errors.IsRetryable
+	}
+	return p
+}
+
+// Do calls the op until it succeeds, fails with a non-retryable error or runs out of the attempts.
+// Every retried failure is logged with the Warn level.
+//
+// Do gives up early if the context is done, or if its deadline comes before the next attempt;
+// the last op's error is returned in every case.
+func Do(ctx context.Context, op func(context.Context) error, p Policy) error {
+	p = p.withDefaults()
+
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil || !p.Retryable(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return errors.Wrapf(err, "giving up after %d attempts", attempt)
+		}
+
+		delay := rand.N(backoff + 1)
+		if d, ok := errors.RetryAfter(err); ok {
+			delay = d
+		}
+		backoff = min(time.Duration(float64(backoff)*p.Multiplier), p.MaxBackoff)
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return errors.Wrapf(err, "giving up after %d attempts, the deadline comes before the next one", attempt)
+		}
+		log.Warne(ctx, "operation failed, retrying", err, "retry.attempt", attempt, "retry.delay", delay)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Wrapf(err, "giving up after %d attempts: %v", attempt, context.Cause(ctx))
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+)
+
+var (
+	errBusy     = errors.NewCoder("BUSY").WithHTTPCode(503)
+	errThrottle = errors.NewCoder("THROTTLED").WithHTTPCode(429).RetryAfter(20 * time.Millisecond)
+	errInvalid  = errors.NewCoder("INVALID").WithHTTPCode(400)
+)
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		so := require.New(t)
+
+		calls := 0
+		err := Do(ctx, func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errBusy.Wrap(errors.New("busy"))
+			}
+			return nil
+		}, policy)
+		so.NoError(err)
+		so.Equal(3, calls)
+	})
+	t.Run("gives up", func(t *testing.T) {
+		so := require.New(t)
+
+		calls := 0
+		err := Do(ctx, func(context.Context) error {
+			calls++
+			return errBusy.Wrap(errors.New("busy"))
+		}, policy)
+		so.ErrorIs(err, errBusy)
+		so.ErrorContains(err, "giving up after 3 attempts")
+		so.Equal(3, calls)
+	})
+	t.Run("non-retryable", func(t *testing.T) {
+		so := require.New(t)
+
+		calls := 0
+		err := Do(ctx, func(context.Context) error {
+			calls++
+			return errInvalid.Wrap(errors.New("bad"))
+		}, policy)
+		so.ErrorIs(err, errInvalid)
+		so.Equal(1, calls)
+	})
+	t.Run("retry after", func(t *testing.T) {
+		so := require.New(t)
+
+		calls := 0
+		start := time.Now()
+		err := Do(ctx, func(context.Context) error {
+			calls++
+			if calls == 1 {
+				return errThrottle.Wrap(errors.New("slow down"))
+			}
+			return nil
+		}, policy)
+		so.NoError(err)
+		so.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+	})
+	t.Run("deadline", func(t *testing.T) {
+		so := require.New(t)
+
+		dctx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		calls := 0
+		err := Do(dctx, func(context.Context) error {
+			calls++
+			return errThrottle.Wrap(errors.New("slow down"))
+		}, policy)
+		so.ErrorIs(err, errThrottle)
+		so.ErrorContains(err, "deadline")
+		so.Equal(1, calls)
+	})
+}
+
+func TestIsRetryable(t *testing.T) {
+	so := require.New(t)
+
+	so.True(errors.IsRetryable(errBusy.Wrap(errors.New("x"))))
+	so.True(errors.IsRetryable(errors.NewCoder("X").WithHTTPCode(500).Retryable().Wrap(errors.New("x"))))
+	so.True(errors.IsRetryable(errors.Wrap(context.DeadlineExceeded, "x")))
+	so.False(errors.IsRetryable(errors.Wrap(context.Canceled, "x")))
+	so.False(errors.IsRetryable(errInvalid.Wrap(errors.New("x"))))
+	so.False(errors.IsRetryable(nil))
+}
@@ -0,0 +1,225 @@
+/*
+Package slogdedup provides an [slog.Handler] which rate-limits similar log records.
+
+A failing dependency in a hot loop may emit thousands of identical errors per second;
+the handler lets the first Burst records of a kind per Interval through and drops the rest,
+emitting a "suppressed N similar log records" summary once the interval is over.
+
+Records are considered similar if they share the level, the message and either the `error.code` attribute
+(see [github.com/utrack/caisson-go/log.Error]) or the call site.
+*/
+package slogdedup
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Options configure the rate limiting.
+type Options struct {
+	// Interval is the rate limiting window for every kind of record.
+	Interval time.Duration
+	// Burst is the number of similar records passed through per Interval.
+	Burst int
+}
+
+var _ slog.Handler = &Handler{}
+
+// Handler is an [slog.Handler] which rate-limits similar log records.
+//
+// The handlers derived via WithAttrs/WithGroup share the limits with their parent.
+type Handler struct {
+	inner slog.Handler
+	st    *state
+}
+
+type state struct {
+	opts Options
+
+	m       sync.Mutex
+	entries map[recordKey]*entry
+
+	suppressed metric.Int64Counter
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+type recordKey struct {
+	level slog.Level
+	msg   string
+	code  string
+	pc    uintptr
+}
+
+type entry struct {
+	windowStart time.Time
+	passed      int
+	suppressed  int
+
+	// inner is the handler which emitted the first record in the window;
+	// it is used to emit the summary.
+	inner slog.Handler
+}
+
+// NewHandler wraps the inner handler with a rate limiter.
+//
+// It starts a goroutine that flushes the summaries; call Close to stop it.
+func NewHandler(inner slog.Handler, opts Options) *Handler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = 1
+	}
+
+	counter, err := otel.Meter("github.com/utrack/caisson-go/pkg/slogdedup").Int64Counter(
+		"log.records.suppressed",
+		metric.WithDescription("Number of log records dropped by the rate limiter"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	st := &state{
+		opts:       opts,
+		entries:    map[recordKey]*entry{},
+		suppressed: counter,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go st.loop()
+
+	return &Handler{inner: inner, st: st}
+}
+
+// Handle implements [slog.Handler].
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	key := recordKey{level: r.Level, msg: r.Message}
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "error.code" {
+			key.code = a.Value.String()
+			return false
+		}
+		return true
+	})
+	if key.code == "" {
+		key.pc = r.PC
+	}
+
+	now := time.Now()
+	h.st.m.Lock()
+	e, ok := h.st.entries[key]
+	var expired entry
+	if !ok || now.Sub(e.windowStart) >= h.st.opts.Interval {
+		if ok {
+			expired = *e
+		}
+		e = &entry{windowStart: now, inner: h.inner}
+		h.st.entries[key] = e
+	}
+	pass := e.passed < h.st.opts.Burst
+	if pass {
+		e.passed++
+	} else {
+		e.suppressed++
+	}
+	h.st.m.Unlock()
+
+	if expired.suppressed > 0 {
+		h.st.summarize(ctx, key, expired)
+	}
+	if !pass {
+		if h.st.suppressed != nil {
+			h.st.suppressed.Add(ctx, 1, metric.WithAttributes(attribute.String("level", r.Level.String())))
+		}
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs implements [slog.Handler].
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{inner: h.inner.WithAttrs(attrs), st: h.st}
+}
+
+// WithGroup implements [slog.Handler].
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name), st: h.st}
+}
+
+// Enabled implements [slog.Handler].
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+// Close stops the flushing goroutine and emits the summaries for all the pending suppressed records.
+func (h *Handler) Close(ctx context.Context) error {
+	h.st.stopOnce.Do(func() {
+		close(h.st.stop)
+	})
+	select {
+	case <-h.st.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *state) loop() {
+	defer close(s.done)
+	t := time.NewTicker(s.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			s.flush(now, false)
+		case <-s.stop:
+			s.flush(time.Now(), true)
+			return
+		}
+	}
+}
+
+// flush emits the summaries for the expired windows and forgets them.
+func (s *state) flush(now time.Time, all bool) {
+	expired := map[recordKey]entry{}
+	s.m.Lock()
+	for k, e := range s.entries {
+		if !all && now.Sub(e.windowStart) < s.opts.Interval {
+			continue
+		}
+		expired[k] = *e
+		delete(s.entries, k)
+	}
+	s.m.Unlock()
+
+	for k, e := range expired {
+		if e.suppressed > 0 {
+			s.summarize(context.Background(), k, e)
+		}
+	}
+}
+
+func (s *state) summarize(ctx context.Context, k recordKey, e entry) {
+	if !e.inner.Enabled(ctx, k.level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), k.level, "suppressed similar log records", k.pc)
+	r.AddAttrs(
+		slog.Int("log.suppressed.count", e.suppressed),
+		slog.String("log.suppressed.message", k.msg),
+		slog.Duration("log.suppressed.interval", s.opts.Interval),
+	)
+	if k.code != "" {
+		r.AddAttrs(slog.String("error.code", k.code))
+	}
+	_ = e.inner.Handle(ctx, r)
+}
@@ -0,0 +1,48 @@
+package slogdedup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_suppressesAndSummarizes(t *testing.T) {
+	so := require.New(t)
+
+	buf := &bytes.Buffer{}
+	h := NewHandler(slog.NewJSONHandler(buf, nil), Options{Interval: time.Hour, Burst: 2})
+	logger := slog.New(h).With("module", "test")
+
+	for i := 0; i < 5; i++ {
+		logger.Error("db is down", "error.code", "DB_DOWN", "attempt", i)
+	}
+	logger.Error("another thing")
+
+	so.NoError(h.Close(context.Background()))
+
+	var lines []map[string]any
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		so.NoError(json.Unmarshal([]byte(l), &m))
+		lines = append(lines, m)
+	}
+
+	so.Len(lines, 4)
+	so.Equal("db is down", lines[0]["msg"])
+	so.Equal("db is down", lines[1]["msg"])
+	so.Equal("another thing", lines[2]["msg"])
+
+	summary := lines[3]
+	so.Equal("suppressed similar log records", summary["msg"])
+	so.Equal("ERROR", summary["level"])
+	so.Equal("db is down", summary["log.suppressed.message"])
+	so.EqualValues(3, summary["log.suppressed.count"])
+	so.Equal("DB_DOWN", summary["error.code"])
+	so.Equal("test", summary["module"])
+}
@@ -0,0 +1,238 @@
+package validate
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/utrack/caisson-go/errors"
+)
+
+// Func is a custom validation rule.
+// It is called with the field's value (dereferenced, if it's a non-nil pointer) and the rule's parameter;
+// the returned error's message is reported as the reason.
+type Func func(v reflect.Value, param string) error
+
+var custom = struct {
+	sync.RWMutex
+	funcs map[string]Func
+}{funcs: map[string]Func{}}
+
+// Register adds a custom rule, usable in the tags as `validate:"name"` or `validate:"name=param"`.
+// Register the rules before the types using them are compiled.
+func Register(name string, fn Func) {
+	custom.Lock()
+	defer custom.Unlock()
+	custom.funcs[name] = fn
+}
+
+// Rule is a single parsed constraint of a field.
+type Rule struct {
+	Name  string
+	Param string
+}
+
+// Rules parses the field's validate tag.
+func Rules(f reflect.StructField) []Rule {
+	tag := f.Tag.Get("validate")
+	if tag == "" {
+		return nil
+	}
+
+	var ret []Rule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "pattern=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		ret = append(ret, Rule{Name: name, Param: param})
+	}
+	return ret
+}
+
+// rule checks the field's value, returning the violation reason if any.
+type rule func(v reflect.Value) string
+
+func compileRules(f reflect.StructField) ([]rule, error) {
+	var ret []rule
+	for _, r := range Rules(f) {
+		fn, err := compileRule(r, f.Type)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule '%v'", r.Name)
+		}
+		ret = append(ret, fn)
+	}
+	return ret, nil
+}
+
+// checkRules runs the rules, returning the first violation.
+func checkRules(rules []rule, v reflect.Value) string {
+	for _, r := range rules {
+		if reason := r(v); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func compileRule(r Rule, t reflect.Type) (rule, error) {
+	switch r.Name {
+	case "required":
+		return func(v reflect.Value) string {
+			if v.IsZero() {
+				return "is required"
+			}
+			return ""
+		}, nil
+	case "min", "max":
+		bound, err := strconv.ParseFloat(r.Param, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad bound")
+		}
+		return boundRule(r.Name == "min", bound, t)
+	case "pattern":
+		re, err := regexp.Compile(r.Param)
+		if err != nil {
+			return nil, errors.Wrap(err, "bad pattern")
+		}
+		if deref(t).Kind() != reflect.String {
+			return nil, errors.Errorf("pattern is applicable to strings only, got %v", t)
+		}
+		return optional(func(v reflect.Value) string {
+			if !re.MatchString(v.String()) {
+				return "must match pattern " + r.Param
+			}
+			return ""
+		}), nil
+	case "enum":
+		allowed := strings.Split(r.Param, "|")
+		return optionalFor(t, func(v reflect.Value) string {
+			s := valueString(v)
+			for _, a := range allowed {
+				if s == a {
+					return ""
+				}
+			}
+			return "must be one of " + strings.Join(allowed, ", ")
+		}), nil
+	}
+
+	custom.RLock()
+	fn, ok := custom.funcs[r.Name]
+	custom.RUnlock()
+	if !ok {
+		return nil, errors.New("unknown rule")
+	}
+	return optional(func(v reflect.Value) string {
+		if err := fn(v, r.Param); err != nil {
+			return err.Error()
+		}
+		return ""
+	}), nil
+}
+
+func boundRule(isMin bool, bound float64, t reflect.Type) (rule, error) {
+	cmp := func(n float64) bool { return n >= bound }
+	word := "at least"
+	if !isMin {
+		cmp = func(n float64) bool { return n <= bound }
+		word = "at most"
+	}
+	param := strconv.FormatFloat(bound, 'f', -1, 64)
+
+	switch deref(t).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return optionalFor(t, func(v reflect.Value) string {
+			if !cmp(float64(v.Int())) {
+				return "must be " + word + " " + param
+			}
+			return ""
+		}), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return optionalFor(t, func(v reflect.Value) string {
+			if !cmp(float64(v.Uint())) {
+				return "must be " + word + " " + param
+			}
+			return ""
+		}), nil
+	case reflect.Float32, reflect.Float64:
+		return optionalFor(t, func(v reflect.Value) string {
+			if !cmp(v.Float()) {
+				return "must be " + word + " " + param
+			}
+			return ""
+		}), nil
+	case reflect.String:
+		return optional(func(v reflect.Value) string {
+			if !cmp(float64(utf8.RuneCountInString(v.String()))) {
+				return "must be " + word + " " + param + " characters long"
+			}
+			return ""
+		}), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return optional(func(v reflect.Value) string {
+			if !cmp(float64(v.Len())) {
+				return "must have " + word + " " + param + " items"
+			}
+			return ""
+		}), nil
+	}
+	return nil, errors.Errorf("bounds are not applicable to %v", t)
+}
+
+// optional skips the rule for the zero values, dereferencing the pointers.
+func optional(r rule) rule {
+	return func(v reflect.Value) string {
+		if v.IsZero() {
+			return ""
+		}
+		for v.Kind() == reflect.Pointer {
+			v = v.Elem()
+		}
+		return r(v)
+	}
+}
+
+// optionalFor is optional, except for the non-pointer numbers: zero is a valid input for them,
+// unlike the empty strings and collections, so it is checked as well.
+func optionalFor(t reflect.Type, r rule) rule {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return r
+	}
+	return optional(r)
+}
+
+func deref(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func valueString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	}
+	return ""
+}
@@ -0,0 +1,231 @@
+/*
+Package validate checks the values against the constraints declared in their `validate` struct tags.
+
+The rules are comma-separated:
+
+	type Input struct {
+		Name  string   `json:"name" validate:"required,max=64,pattern=^[a-z]+$"`
+		Kind  string   `json:"kind" validate:"enum=a|b|c"`
+		Tags  []string `json:"tags" validate:"min=1"`
+		Email string   `json:"email" validate:"email"` // registered via Register()
+	}
+
+Built-in rules are required, min, max, pattern and enum.
+min and max bound the numbers' values and the strings', slices' and maps' lengths.
+pattern consumes the rest of the tag, so it should be the last rule.
+The rules other than required are skipped for the zero values, except for min, max and enum
+of the non-pointer numbers: their zeros are checked. Use the pointers for the optional numbers.
+
+Violations are reported as ErrInvalid errors; InvalidFields() lists the invalid fields
+with their JSON pointers (built from the json tags) and the reasons.
+*/
+package validate
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/utrack/caisson-go/errors"
+)
+
+// ErrInvalid is returned when the value violates its constraints.
+var ErrInvalid = errors.Register(errors.NewCoder("VALIDATION_FAILED").WithHTTPCode(http.StatusUnprocessableEntity).WithMessage("request validation failed"))
+
+const invalidFieldsKey = "invalid_fields"
+
+// InvalidField describes a single constraint violation.
+type InvalidField struct {
+	// Pointer is the JSON pointer to the field, like /items/0/name.
+	Pointer string `json:"pointer"`
+	Reason  string `json:"reason"`
+}
+
+// InvalidFields returns the fields reported by the ErrInvalid error.
+func InvalidFields(err error) []InvalidField {
+	ret, ok := errors.KeyedData[string, *[]InvalidField](err, invalidFieldsKey)
+	if !ok || ret == nil {
+		return nil
+	}
+	return *ret
+}
+
+// Validator validates the values of a single type.
+type Validator struct {
+	t    reflect.Type
+	plan *typePlan
+}
+
+// Compile prepares the validator for the type, checking its rules.
+func Compile(t reflect.Type) (*Validator, error) {
+	plan, err := compileType(t, map[reflect.Type]*typePlan{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "when compiling validation rules for %v", t)
+	}
+	return &Validator{t: t, plan: plan}, nil
+}
+
+// Empty reports whether the type has no constraints at all.
+func (v *Validator) Empty() bool {
+	return v.plan == nil
+}
+
+// Validate checks the value, which should be of the validator's type or a pointer to it.
+func (v *Validator) Validate(val any) error {
+	if v.plan == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Type() != v.t && rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+	}
+
+	var fields []InvalidField
+	v.plan.validate(rv, "", &fields)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(fields))
+	for _, f := range fields {
+		reasons = append(reasons, f.Pointer+": "+f.Reason)
+	}
+	err := ErrInvalid.Wrap(errors.Errorf("invalid fields: %v", strings.Join(reasons, "; ")))
+	return errors.WithPublicData(err, invalidFieldsKey, &fields)
+}
+
+var cache sync.Map
+
+// Validate checks the value against the constraints of its type.
+func Validate(val any) error {
+	t := reflect.TypeOf(val)
+	if t == nil {
+		return nil
+	}
+	if v, ok := cache.Load(t); ok {
+		return v.(*Validator).Validate(val)
+	}
+	v, err := Compile(t)
+	if err != nil {
+		return err
+	}
+	cache.Store(t, v)
+	return v.Validate(val)
+}
+
+// typePlan is the compiled validation of a type; nil plans validate nothing.
+type typePlan struct {
+	// fields are set for the structs
+	fields []fieldPlan
+	// elem is set for the pointers, slices, arrays and maps
+	elem *typePlan
+}
+
+type fieldPlan struct {
+	index int
+	name  string
+	rules []rule
+	plan  *typePlan
+}
+
+func compileType(t reflect.Type, seen map[reflect.Type]*typePlan) (*typePlan, error) {
+	if p, ok := seen[t]; ok {
+		return p, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		elem, err := compileType(t.Elem(), seen)
+		if err != nil || elem == nil {
+			return nil, err
+		}
+		return &typePlan{elem: elem}, nil
+	case reflect.Struct:
+	default:
+		return nil, nil
+	}
+
+	// register before descending, so that the recursive types terminate
+	p := &typePlan{}
+	seen[t] = p
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		rules, err := compileRules(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %v", f.Name)
+		}
+		nested, err := compileType(f.Type, seen)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %v", f.Name)
+		}
+		if len(rules) == 0 && nested == nil {
+			continue
+		}
+		p.fields = append(p.fields, fieldPlan{index: i, name: FieldName(f), rules: rules, plan: nested})
+	}
+	if len(p.fields) == 0 {
+		// the plan might be referenced by a recursive field already, so keep it
+		delete(seen, t)
+		return nil, nil
+	}
+	return p, nil
+}
+
+func (p *typePlan) validate(rv reflect.Value, ptr string, out *[]InvalidField) {
+	if p == nil {
+		return
+	}
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if !rv.IsNil() {
+			p.elem.validate(rv.Elem(), ptr, out)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			p.elem.validate(rv.Index(i), fmt.Sprintf("%v/%d", ptr, i), out)
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			p.elem.validate(iter.Value(), ptr+"/"+escape(fmt.Sprint(iter.Key().Interface())), out)
+		}
+	case reflect.Struct:
+		for _, f := range p.fields {
+			fv := rv.Field(f.index)
+			fptr := ptr + "/" + escape(f.name)
+
+			if reason := checkRules(f.rules, fv); reason != "" {
+				*out = append(*out, InvalidField{Pointer: fptr, Reason: reason})
+				continue
+			}
+			f.plan.validate(fv, fptr, out)
+		}
+	}
+}
+
+// FieldName is the field's name in the JSON pointers: its json tag's name,
+// the name of the httpin's directive (like tenant for `in:"query=tenant"`), or the field's name.
+func FieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name != "" && name != "-" {
+		return name
+	}
+	if in := f.Tag.Get("in"); in != "" {
+		dir, _, _ := strings.Cut(in, ";")
+		_, keys, _ := strings.Cut(dir, "=")
+		if key, _, _ := strings.Cut(keys, ","); key != "" {
+			return key
+		}
+	}
+	return f.Name
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
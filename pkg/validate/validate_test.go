@@ -0,0 +1,88 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/utrack/caisson-go/errors"
+)
+
+type testItem struct {
+	Name string `json:"name" validate:"required,max=3"`
+}
+
+type testInput struct {
+	Name  string     `json:"name" validate:"required,min=2,pattern=^[a-z,]+$"`
+	Kind  string     `json:"kind" validate:"enum=a|b"`
+	Count *int       `json:"count" validate:"min=1"`
+	Items []testItem `json:"items" validate:"max=2"`
+	Even  int        `json:"even" validate:"even"`
+	Next  *testInput `json:"next"`
+}
+
+func TestValidate(t *testing.T) {
+	so := require.New(t)
+	Register("even", func(v reflect.Value, _ string) error {
+		if v.Int()%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+
+	zero := 0
+	so.NoError(Validate(testInput{Name: "a,b", Kind: "b", Items: []testItem{{Name: "x"}}}))
+
+	err := Validate(&testInput{
+		Name:  "A",
+		Kind:  "c",
+		Count: &zero,
+		Items: []testItem{{Name: "x"}, {Name: "long"}},
+		Even:  3,
+		Next:  &testInput{Name: "ok"},
+	})
+	so.ErrorIs(err, ErrInvalid)
+	so.Equal(422, errors.Code(err).HTTPCode())
+	so.Equal([]InvalidField{
+		{"/name", "must be at least 2 characters long"},
+		{"/kind", "must be one of a, b"},
+		{"/count", "must be at least 1"},
+		{"/items/1/name", "must be at most 3 characters long"},
+		{"/even", "must be even"},
+	}, InvalidFields(err))
+
+	err = Validate(testInput{Name: "ab", Next: &testInput{}})
+	so.Equal([]InvalidField{{"/next/name", "is required"}}, InvalidFields(err))
+}
+
+func TestValidate_zeroNumbers(t *testing.T) {
+	so := require.New(t)
+
+	type input struct {
+		Age   int      `json:"age" validate:"min=18"`
+		Level uint     `json:"level" validate:"enum=1|2"`
+		Limit *float64 `json:"limit" validate:"max=-1"`
+	}
+	err := Validate(input{})
+	so.Equal([]InvalidField{
+		{"/age", "must be at least 18"},
+		{"/level", "must be one of 1, 2"},
+	}, InvalidFields(err))
+
+	so.NoError(Validate(input{Age: 18, Level: 2}))
+}
+
+func TestCompile_badRules(t *testing.T) {
+	so := require.New(t)
+
+	_, err := Compile(reflect.TypeFor[struct {
+		A bool `validate:"min=1"`
+	}]())
+	so.Error(err)
+
+	_, err = Compile(reflect.TypeFor[struct {
+		A string `validate:"unknown"`
+	}]())
+	so.True(strings.Contains(err.Error(), "unknown rule"))
+}